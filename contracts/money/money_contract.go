@@ -2,15 +2,49 @@ package contracts
 
 import (
 	"github.com/nspcc-dev/neo-go/pkg/interop"
+	"github.com/nspcc-dev/neo-go/pkg/interop/contract"
+	"github.com/nspcc-dev/neo-go/pkg/interop/iterator"
 	"github.com/nspcc-dev/neo-go/pkg/interop/native/gas"
+	"github.com/nspcc-dev/neo-go/pkg/interop/native/ledger"
+	"github.com/nspcc-dev/neo-go/pkg/interop/native/neo"
+	"github.com/nspcc-dev/neo-go/pkg/interop/native/std"
 	"github.com/nspcc-dev/neo-go/pkg/interop/runtime"
 	"github.com/nspcc-dev/neo-go/pkg/interop/storage"
 )
 
 const (
-	contractOwnerKey = "o"
+	contractOwnerKey   = "o"
+	gamePrefix         = "g" // g<contractHash> -> true, game contracts allowed to move tokens
+	tokenPrefix        = "a" // a<token> -> true, NEP-17 tokens allowed for rooms besides GAS and NEO
+	roomTokenPrefix    = "t" // t<contractHash><roomId> -> token of the room, escrow and claims of the room are kept in it
+	escrowPrefix       = "e" // e<contractHash><roomId> -> tokens held by the contract for the room of the game contract
+	earningsPrefix     = "s" // s<token> -> settled earnings of the platform treasury, available for PlatformWithdrawal
+	platformFeeKey     = "f" // Platform fee in basis points, taken from every room payout into the treasury
+	pendingFeeKey      = "p" // Proposed platform fee, applied after the change delay
+	hostEarningsPrefix = "h" // h<host><token> -> settled earnings of the host, available for HostWithdrawal
+	claimPrefix        = "c" // c<wallet><roomId>:<contractHash> -> tokens the wallet can claim from the room
+	balancePrefix      = "b" // b<wallet><token> -> prepaid balance of the player, game contracts charge room fees from it
+	spendingPrefix     = "l" // l<wallet><contractHash><roomId> -> spending of the player in the room and its cap
 )
 
+// Platform fee governance bounds
+const (
+	maxPlatformFee = 1000 // Platform fee can not be greater than 10% of the pool
+	feeChangeDelay = 5760 // Blocks between proposal of the new fee and its activation, about a day
+)
+
+// PendingFee is a proposed platform fee, it becomes the platform fee from block ActivatesAt
+type PendingFee struct {
+	Fee         int
+	ActivatesAt int
+}
+
+// RoomSpending is a prepaid balance spent by the player in the room, Cap is optional limit of Spent, 0 means no limit
+type RoomSpending struct {
+	Cap   int
+	Spent int
+}
+
 func _deploy(_ interface{}, isUpdate bool) {
 	if isUpdate {
 		return
@@ -21,10 +55,15 @@ func _deploy(_ interface{}, isUpdate bool) {
 	storage.Put(ctx, contractOwnerKey, owner)
 }
 
-// GetBalance allows the host to get the contract balance
-func GetBalance() int {
+// GetBalance allows the host to get the contract balance of the token
+func GetBalance(token interop.Hash160) int {
 	var contractHash = runtime.GetExecutingScriptHash()
-	return gas.BalanceOf(contractHash)
+	return contract.Call(token, "balanceOf", contract.ReadStates, contractHash).(int)
+}
+
+// Function to transfer NEP-17 tokens, GAS and NEO are transferred the same way as other tokens
+func transferToken(token interop.Hash160, from interop.Hash160, to interop.Hash160, amount int, data any) bool {
+	return contract.Call(token, "transfer", contract.All, from, to, amount, data).(bool)
 }
 
 func getOwner() interop.Hash160 {
@@ -35,20 +74,200 @@ func getOwner() interop.Hash160 {
 		panic("Owner not set")
 	}
 
-	ownerHash, ok := owner.(interop.Hash160)
-	if !ok {
-		panic("Stored owner is not a valid Hash160")
+	return owner.(interop.Hash160)
+}
+
+// SetOwner allows owner to pass ownership to another account, e.g. multisig account of the platform
+func SetOwner(newOwner interop.Hash160) bool {
+	var owner = getOwner()
+	if !runtime.CheckWitness(owner) || len(newOwner) != interop.Hash160Len {
+		return false // Only owner can change owner
+	}
+
+	storage.Put(storage.GetContext(), contractOwnerKey, newOwner)
+	runtime.Notify("OwnerChanged", owner, newOwner)
+	return true
+}
+
+func GetOwner() interop.Hash160 {
+	return getOwner()
+}
+
+// Function to check that method is called by authorized game contract
+func isCallerAuthorized(ctx storage.Context) bool {
+	var caller = runtime.GetCallingScriptHash()
+	return storage.Get(ctx, append([]byte(gamePrefix), caller...)) != nil
+}
+
+func makeEscrowKey(game interop.Hash160, roomId string) string {
+	return escrowPrefix + string(game) + roomId
+}
+
+// AuthorizeGame allows owner to add game contract, which can deposit and pay out tokens
+func AuthorizeGame(game interop.Hash160) bool {
+	var ctx = storage.GetContext()
+	var owner = getOwner()
+
+	if !runtime.CheckWitness(owner) || len(game) != interop.Hash160Len {
+		return false // Only owner can authorize game contract
+	}
+
+	storage.Put(ctx, append([]byte(gamePrefix), game...), true)
+	runtime.Notify("GameAuthorized", game, owner)
+	return true
+}
+
+// RevokeGame allows owner to remove game contract from authorized ones
+func RevokeGame(game interop.Hash160) bool {
+	var ctx = storage.GetContext()
+	var owner = getOwner()
+
+	if !runtime.CheckWitness(owner) || !IsGameAuthorized(game) {
+		return false // Only owner can revoke game contract, game contract must be authorized
+	}
+
+	storage.Delete(ctx, append([]byte(gamePrefix), game...))
+	runtime.Notify("GameRevoked", game, owner)
+	return true
+}
+
+func IsGameAuthorized(game interop.Hash160) bool {
+	return storage.Get(storage.GetReadOnlyContext(), append([]byte(gamePrefix), game...)) != nil
+}
+
+func isTokenAllowed(ctx storage.Context, token interop.Hash160) bool {
+	return token.Equals(gas.Hash) || token.Equals(neo.Hash) || storage.Get(ctx, append([]byte(tokenPrefix), token...)) != nil
+}
+
+// AllowToken allows owner to add NEP-17 token, in which rooms can be created. GAS and NEO are always allowed
+func AllowToken(token interop.Hash160) bool {
+	var ctx = storage.GetContext()
+	var owner = getOwner()
+
+	if !runtime.CheckWitness(owner) || len(token) != interop.Hash160Len || isTokenAllowed(ctx, token) {
+		return false // Only owner can allow token, token must not be allowed yet
+	}
+
+	storage.Put(ctx, append([]byte(tokenPrefix), token...), true)
+	runtime.Notify("TokenAllowed", token, owner)
+	return true
+}
+
+// DisallowToken allows owner to remove token from allowed ones, rooms already created in the token keep working
+func DisallowToken(token interop.Hash160) bool {
+	var ctx = storage.GetContext()
+	var owner = getOwner()
+
+	if !runtime.CheckWitness(owner) || storage.Get(ctx, append([]byte(tokenPrefix), token...)) == nil {
+		return false // Only owner can disallow token, GAS and NEO can not be disallowed
+	}
+
+	storage.Delete(ctx, append([]byte(tokenPrefix), token...))
+	runtime.Notify("TokenDisallowed", token, owner)
+	return true
+}
+
+func IsTokenAllowed(token interop.Hash160) bool {
+	return isTokenAllowed(storage.GetReadOnlyContext(), token)
+}
+
+func makeRoomTokenKey(game interop.Hash160, roomId string) string {
+	return roomTokenPrefix + string(game) + roomId
+}
+
+// Function to get token of the room, nil if room was not opened
+func getRoomToken(ctx storage.Context, game interop.Hash160, roomId string) interop.Hash160 {
+	var token = storage.Get(ctx, makeRoomTokenKey(game, roomId))
+	if token == nil {
+		return nil
+	}
+
+	return token.(interop.Hash160)
+}
+
+// GetRoomToken returns token of the room of the game contract
+func GetRoomToken(game interop.Hash160, roomId string) interop.Hash160 {
+	return getRoomToken(storage.GetReadOnlyContext(), game, roomId)
+}
+
+// OpenRoom sets token of the new room, all commissions and rewards of the room are paid in it.
+// Can be called only by authorized game contract, token must be allowed
+func OpenRoom(roomId string, token interop.Hash160) bool {
+	var ctx = storage.GetContext()
+	if !isCallerAuthorized(ctx) {
+		runtime.Log("Caller is not authorized game contract")
+		return false
+	}
+
+	var game = runtime.GetCallingScriptHash()
+	if !isTokenAllowed(ctx, token) || getRoomToken(ctx, game, roomId) != nil {
+		runtime.Log("Token is not allowed or room is already opened")
+		return false
+	}
+
+	storage.Put(ctx, makeRoomTokenKey(game, roomId), token)
+	runtime.Notify("RoomOpened", game, roomId, token)
+	return true
+}
+
+func getInt(ctx storage.Context, key string) int {
+	var value = storage.Get(ctx, key)
+	if value == nil {
+		return 0
+	}
+
+	return value.(int)
+}
+
+// Function to change stored balance, balance can never be below zero
+func addInt(ctx storage.Context, key string, amount int) bool {
+	var balance = getInt(ctx, key) + amount
+	if balance < 0 {
+		return false
+	}
+
+	if balance == 0 {
+		storage.Delete(ctx, key)
+	} else {
+		storage.Put(ctx, key, balance)
 	}
+	return true
+}
+
+// GetRoomEscrow returns tokens held by the contract for the room of the game contract, in the token of the room
+func GetRoomEscrow(game interop.Hash160, roomId string) int {
+	return getInt(storage.GetReadOnlyContext(), makeEscrowKey(game, roomId))
+}
+
+// GetPlatformEarnings returns platform earnings in the token available for PlatformWithdrawal
+func GetPlatformEarnings(token interop.Hash160) int {
+	return getInt(storage.GetReadOnlyContext(), makeEarningsKey(token))
+}
+
+// GetHostEarnings returns earnings of the host in the token available for HostWithdrawal
+func GetHostEarnings(host interop.Hash160, token interop.Hash160) int {
+	return getInt(storage.GetReadOnlyContext(), makeHostEarningsKey(host, token))
+}
+
+func makeEarningsKey(token interop.Hash160) string {
+	return earningsPrefix + string(token)
+}
 
-	return ownerHash
+func makeHostEarningsKey(host interop.Hash160, token interop.Hash160) string {
+	return hostEarningsPrefix + string(host) + string(token)
 }
 
-// HostWithdrawal allows host to claim funds from played game
-func HostWithdrawal(amount int) bool {
+// Function to transfer tokens stored under the key - settled earnings or prepaid balance - to the wallet.
+// Balance is reduced before the transfer, so wallet contract can not withdraw it again from OnNEP17Payment
+func withdraw(ctx storage.Context, key string, token interop.Hash160, wallet interop.Hash160, amount int) bool {
 	var contractHash = runtime.GetExecutingScriptHash()
-	var ownerHash = getOwner()
+	if amount <= 0 || !addInt(ctx, key, -amount) {
+		runtime.Log("Not enough tokens to withdraw")
+		return false
+	}
 
-	if !gas.Transfer(contractHash, ownerHash, amount, nil) {
+	if !transferToken(token, contractHash, wallet, amount, nil) {
+		addInt(ctx, key, amount)
 		runtime.Log("Failed to withdraw tokens")
 		return false
 	}
@@ -56,33 +275,402 @@ func HostWithdrawal(amount int) bool {
 	return true
 }
 
-// Deposit transfers tokens to the contract balance from wallet
-func Deposit(wallet interop.Hash160, amount int) bool {
-	var contractHash = runtime.GetExecutingScriptHash()
-	if !gas.Transfer(wallet, contractHash, amount, nil) {
-		runtime.Log("Failed to deposit tokens")
+// HostWithdrawal allows host to claim his own earnings from played games, only settled earnings can be withdrawn,
+// tokens of running games stay in escrow of the rooms
+func HostWithdrawal(host interop.Hash160, token interop.Hash160, amount int) bool {
+	if !runtime.CheckWitness(host) {
+		runtime.Log("Only host can withdraw his earnings")
+		return false
+	}
+
+	var ctx = storage.GetContext()
+	if !withdraw(ctx, makeHostEarningsKey(host, token), token, host, amount) {
 		return false
 	}
 
-	runtime.Log("Successfully deposited tokens from wallet")
+	runtime.Notify("HostWithdrawn", host, token, amount)
 	return true
 }
 
-// RewardPlayer transfers tokens from the contract balance to the player's wallet
-func RewardPlayer(wallet interop.Hash160, amount int) bool {
+// PlatformWithdrawal allows owner to claim platform earnings in the token
+func PlatformWithdrawal(token interop.Hash160, amount int) bool {
+	var ownerHash = getOwner()
+	if !runtime.CheckWitness(ownerHash) {
+		runtime.Log("Only owner can withdraw tokens")
+		return false
+	}
+
+	return withdraw(storage.GetContext(), makeEarningsKey(token), token, ownerHash, amount)
+}
+
+func getPendingFee(ctx storage.Context) (PendingFee, bool) {
+	var data = storage.Get(ctx, pendingFeeKey)
+	if data == nil {
+		return PendingFee{}, false
+	}
+
+	return std.Deserialize(data.([]byte)).(PendingFee), true
+}
+
+// Function to get platform fee in effect, proposed fee is in effect from its activation block
+func getPlatformFee(ctx storage.Context) int {
+	var pending, exist = getPendingFee(ctx)
+	if exist && ledger.CurrentIndex() >= pending.ActivatesAt {
+		return pending.Fee
+	}
+
+	return getInt(ctx, platformFeeKey)
+}
+
+// GetPlatformFee returns platform fee in basis points, game contracts take it from every payout of new rooms
+func GetPlatformFee() int {
+	return getPlatformFee(storage.GetReadOnlyContext())
+}
+
+// GetPendingPlatformFee returns proposed platform fee that is not in effect yet, nil if there is none
+func GetPendingPlatformFee() map[string]any {
+	var pending, exist = getPendingFee(storage.GetReadOnlyContext())
+	if !exist || ledger.CurrentIndex() >= pending.ActivatesAt {
+		return nil
+	}
+
+	return map[string]any{
+		"fee":         pending.Fee,
+		"activatesAt": pending.ActivatesAt,
+	}
+}
+
+// ProposePlatformFee allows owner to change platform fee, new fee comes into effect after feeChangeDelay blocks,
+// so players can see it before joining the rooms. New proposal replaces the previous one that is not in effect yet
+func ProposePlatformFee(fee int) bool {
+	var owner = getOwner()
+	if !runtime.CheckWitness(owner) {
+		runtime.Log("Only owner can change platform fee")
+		return false
+	}
+
+	if fee < 0 || fee > maxPlatformFee {
+		runtime.Log("Platform fee is out of bounds")
+		return false
+	}
+
+	var ctx = storage.GetContext()
+	var current = getPlatformFee(ctx)
+	storage.Put(ctx, platformFeeKey, current)
+
+	var pending = PendingFee{Fee: fee, ActivatesAt: ledger.CurrentIndex() + feeChangeDelay}
+	storage.Put(ctx, pendingFeeKey, std.Serialize(pending))
+	runtime.Notify("PlatformFeeProposed", current, fee, pending.ActivatesAt)
+	return true
+}
+
+// CancelPlatformFee allows owner to cancel proposed platform fee before it comes into effect
+func CancelPlatformFee() bool {
+	var owner = getOwner()
+	if !runtime.CheckWitness(owner) {
+		runtime.Log("Only owner can change platform fee")
+		return false
+	}
+
+	var ctx = storage.GetContext()
+	var pending, exist = getPendingFee(ctx)
+	if !exist || ledger.CurrentIndex() >= pending.ActivatesAt {
+		runtime.Log("No proposed platform fee to cancel")
+		return false
+	}
+
+	storage.Delete(ctx, pendingFeeKey)
+	runtime.Notify("PlatformFeeCancelled", pending.Fee, getInt(ctx, platformFeeKey))
+	return true
+}
+
+// OnNEP17Payment accepts allowed tokens: fees paid to authorized game contracts are credited to the room escrow,
+// data is the room id and the token must be the token of the room. Tokens from other senders top up their prepaid balance
+func OnNEP17Payment(from interop.Hash160, amount int, data any) {
+	var ctx = storage.GetContext()
+	var token = runtime.GetCallingScriptHash()
+	if from == nil && token.Equals(gas.Hash) {
+		return // GAS generated by NEO held by the contract
+	}
+
+	if len(from) != interop.Hash160Len {
+		panic("Sender must be Hash160")
+	}
+
+	// Deposits of the game are checked only by the room token, so rooms keep working after DisallowToken
+	if storage.Get(ctx, append([]byte(gamePrefix), from...)) != nil {
+		var roomId = data.(string)
+		if !token.Equals(getRoomToken(ctx, from, roomId)) {
+			panic("Token is not the token of the room")
+		}
+
+		addInt(ctx, makeEscrowKey(from, roomId), amount)
+		runtime.Notify("Deposited", from, roomId, amount)
+		return
+	}
+
+	if !isTokenAllowed(ctx, token) {
+		panic("Token is not allowed")
+	}
+
+	addInt(ctx, makeBalanceKey(from, token), amount)
+	runtime.Notify("BalanceToppedUp", from, token, amount)
+}
+
+func makeBalanceKey(wallet interop.Hash160, token interop.Hash160) string {
+	return balancePrefix + string(wallet) + string(token)
+}
+
+func makeSpendingKey(wallet interop.Hash160, game interop.Hash160, roomId string) string {
+	return spendingPrefix + string(wallet) + string(game) + roomId
+}
+
+func getSpending(ctx storage.Context, key string) RoomSpending {
+	var data = storage.Get(ctx, key)
+	if data == nil {
+		return RoomSpending{}
+	}
+
+	return std.Deserialize(data.([]byte)).(RoomSpending)
+}
+
+// BalanceOfPlayer returns prepaid balance of the player in the token
+func BalanceOfPlayer(wallet interop.Hash160, token interop.Hash160) int {
+	return getInt(storage.GetReadOnlyContext(), makeBalanceKey(wallet, token))
+}
+
+// WithdrawBalance transfers unspent prepaid balance in the token back to the wallet
+func WithdrawBalance(wallet interop.Hash160, token interop.Hash160, amount int) bool {
+	if !runtime.CheckWitness(wallet) {
+		runtime.Log("Only owner of the wallet can withdraw the balance")
+		return false
+	}
+
+	var ctx = storage.GetContext()
+	if !withdraw(ctx, makeBalanceKey(wallet, token), token, wallet, amount) {
+		return false
+	}
+
+	runtime.Notify("BalanceWithdrawn", wallet, token, amount)
+	return true
+}
+
+// SetSpendingCap limits the prepaid balance the room of the game contract can charge from the wallet,
+// already charged fees are counted too. Cap 0 removes the limit
+func SetSpendingCap(wallet interop.Hash160, game interop.Hash160, roomId string, cap int) bool {
+	if !runtime.CheckWitness(wallet) || cap < 0 {
+		runtime.Log("Only owner of the wallet can set spending cap, cap must not be negative")
+		return false
+	}
+
+	var ctx = storage.GetContext()
+	var key = makeSpendingKey(wallet, game, roomId)
+	var spending = getSpending(ctx, key)
+	spending.Cap = cap
+	storage.Put(ctx, key, std.Serialize(spending))
+	runtime.Notify("SpendingCapSet", wallet, game, roomId, cap)
+	return true
+}
+
+// GetSpending returns prepaid balance charged by the room from the wallet and the spending cap of the room
+func GetSpending(wallet interop.Hash160, game interop.Hash160, roomId string) map[string]any {
+	var spending = getSpending(storage.GetReadOnlyContext(), makeSpendingKey(wallet, game, roomId))
+	return map[string]any{
+		"cap":   spending.Cap,
+		"spent": spending.Spent,
+	}
+}
+
+// Charge moves room fee from the prepaid balance of the wallet in the token of the room to the room escrow,
+// spending cap of the room is respected.
+// Can be called only by authorized game contract, game contract is responsible for checking the wallet's witness
+func Charge(roomId string, wallet interop.Hash160, amount int) bool {
+	var ctx = storage.GetContext()
+	if !isCallerAuthorized(ctx) {
+		runtime.Log("Caller is not authorized game contract")
+		return false
+	}
+
+	var game = runtime.GetCallingScriptHash()
+	var token = getRoomToken(ctx, game, roomId)
+	if token == nil {
+		runtime.Log("Room is not opened")
+		return false
+	}
+
+	var key = makeSpendingKey(wallet, game, roomId)
+	var spending = getSpending(ctx, key)
+	if amount < 0 || (spending.Cap > 0 && spending.Spent+amount > spending.Cap) {
+		runtime.Log("Room spending cap is reached")
+		return false
+	}
+
+	if !addInt(ctx, makeBalanceKey(wallet, token), -amount) {
+		runtime.Log("Not enough prepaid balance")
+		return false
+	}
+
+	spending.Spent += amount
+	storage.Put(ctx, key, std.Serialize(spending))
+	addInt(ctx, makeEscrowKey(game, roomId), amount)
+	runtime.Notify("BalanceCharged", wallet, roomId, amount)
+	return true
+}
+
+// RewardPlayer credits tokens from the room escrow to the player's claimable balance, player withdraws them with Claim.
+// Can be called only by authorized game contract
+func RewardPlayer(roomId string, wallet interop.Hash160, amount int) bool {
+	var ctx = storage.GetContext()
+	if !isCallerAuthorized(ctx) {
+		runtime.Log("Caller is not authorized game contract")
+		return false
+	}
+
+	var game = runtime.GetCallingScriptHash()
+	if amount < 0 || len(wallet) != interop.Hash160Len || !addInt(ctx, makeEscrowKey(game, roomId), -amount) {
+		runtime.Log("Not enough tokens in room escrow")
+		return false
+	}
+
+	addInt(ctx, string(makeClaimKey(wallet, roomId, game)), amount)
+	runtime.Notify("RewardCredited", wallet, roomId, amount)
+	return true
+}
+
+func makeClaimKey(wallet interop.Hash160, roomId string, game interop.Hash160) []byte {
+	return append(makeClaimPrefix(wallet, roomId), game...)
+}
+
+// Function to make prefix of claims of wallet, empty roomId means claims of all rooms
+func makeClaimPrefix(wallet interop.Hash160, roomId string) []byte {
+	var prefix = append([]byte(claimPrefix), wallet...)
+	if roomId == "" {
+		return prefix
+	}
+
+	return append(prefix, []byte(roomId+":")...)
+}
+
+// Function to get room of the claim by its key, the key is c<wallet><roomId>:<contractHash>
+func claimRoom(key []byte) (interop.Hash160, string) {
+	var game = interop.Hash160(key[len(key)-interop.Hash160Len:])
+	var roomId = string(key[1+interop.Hash160Len : len(key)-interop.Hash160Len-1])
+	return game, roomId
+}
+
+// Function to sum claims with prefix in the token, nil token means claims in every token
+func sumClaims(ctx storage.Context, prefix []byte, token interop.Hash160) int {
+	var iter = storage.Find(ctx, prefix, storage.KeysOnly)
+
+	var total = 0
+	for iterator.Next(iter) {
+		var key = iterator.Value(iter).([]byte)
+		var game, roomId = claimRoom(key)
+		if token == nil || token.Equals(getRoomToken(ctx, game, roomId)) {
+			total += getInt(ctx, string(key))
+		}
+	}
+
+	return total
+}
+
+// Function to transfer all claims with prefix to the wallet, every claim is paid in the token of its room.
+// Claims are removed before transfers, so wallet contract can not claim them again from OnNEP17Payment
+func claim(ctx storage.Context, wallet interop.Hash160, prefix []byte) bool {
+	var keys = []string{}
+	var amounts = []int{}
+	var iter = storage.Find(ctx, prefix, storage.KeysOnly)
+	for iterator.Next(iter) {
+		var key = string(iterator.Value(iter).([]byte))
+		keys = append(keys, key)
+		amounts = append(amounts, getInt(ctx, key))
+		storage.Delete(ctx, key)
+	}
+
+	if len(keys) == 0 {
+		runtime.Log("Nothing to claim")
+		return false
+	}
+
 	var contractHash = runtime.GetExecutingScriptHash()
-	if !gas.Transfer(contractHash, wallet, amount, nil) {
-		runtime.Log("Failed to transfer tokens to player")
+	for i, key := range keys {
+		var game, roomId = claimRoom([]byte(key))
+		var token = getRoomToken(ctx, game, roomId)
+		if !transferToken(token, contractHash, wallet, amounts[i], nil) {
+			for j := i; j < len(keys); j++ {
+				addInt(ctx, keys[j], amounts[j]) // Not transferred claims stay claimable
+			}
+			runtime.Log("Failed to transfer tokens to player")
+			return false
+		}
+
+		runtime.Notify("Claimed", wallet, token, amounts[i])
+	}
+
+	return true
+}
+
+// Claim transfers rewards and refunds of all rooms to the wallet, wallet must sign the transaction
+func Claim(wallet interop.Hash160) bool {
+	if !runtime.CheckWitness(wallet) {
+		runtime.Log("Only owner of the wallet can claim")
+		return false
+	}
+
+	return claim(storage.GetContext(), wallet, makeClaimPrefix(wallet, ""))
+}
+
+// ClaimFor transfers rewards and refunds of the room to the wallet, wallet must sign the transaction
+func ClaimFor(wallet interop.Hash160, roomId string) bool {
+	if !runtime.CheckWitness(wallet) {
+		runtime.Log("Only owner of the wallet can claim")
 		return false
 	}
 
-	runtime.Log("Successfully transferred tokens to player")
+	return claim(storage.GetContext(), wallet, makeClaimPrefix(wallet, roomId))
+}
+
+// GetPendingClaims returns tokens the wallet can claim from all rooms in the token
+func GetPendingClaims(wallet interop.Hash160, token interop.Hash160) int {
+	return sumClaims(storage.GetReadOnlyContext(), makeClaimPrefix(wallet, ""), token)
+}
+
+// GetPendingClaimsFor returns tokens the wallet can claim from the room, in the token of the room
+func GetPendingClaimsFor(wallet interop.Hash160, roomId string) int {
+	return sumClaims(storage.GetReadOnlyContext(), makeClaimPrefix(wallet, roomId), nil)
+}
+
+// Settle moves part of the room escrow to settled earnings of the host and the platform, when room's payout is done.
+// Can be called only by authorized game contract
+func Settle(roomId string, host interop.Hash160, hostAmount int, platformAmount int) bool {
+	var ctx = storage.GetContext()
+	if !isCallerAuthorized(ctx) {
+		runtime.Log("Caller is not authorized game contract")
+		return false
+	}
+
+	var game = runtime.GetCallingScriptHash()
+	if hostAmount < 0 || platformAmount < 0 || len(host) != interop.Hash160Len ||
+		!addInt(ctx, makeEscrowKey(game, roomId), -(hostAmount+platformAmount)) {
+		runtime.Log("Not enough tokens in room escrow")
+		return false
+	}
+
+	var token = getRoomToken(ctx, game, roomId)
+	addInt(ctx, makeHostEarningsKey(host, token), hostAmount)
+	addInt(ctx, makeEarningsKey(token), platformAmount)
+	runtime.Notify("EarningsSettled", roomId, host, hostAmount, platformAmount)
 	return true
 }
 
-// Transfer tokens between wallets
-func Transfer(from interop.Hash160, to interop.Hash160, amount int) bool {
-	if !gas.Transfer(from, to, amount, nil) {
+// Transfer tokens between wallets, can be called only by authorized game contract with witness of the sender
+func Transfer(token interop.Hash160, from interop.Hash160, to interop.Hash160, amount int) bool {
+	if !isCallerAuthorized(storage.GetReadOnlyContext()) || !runtime.CheckWitness(from) {
+		runtime.Log("Transfer is not authorized")
+		return false
+	}
+
+	if !transferToken(token, from, to, amount, nil) {
 		runtime.Log("Failed to transfer tokens")
 		return false
 	}
@@ -1,7 +1,6 @@
 package nft
 
 import (
-	"fmt"
 	"github.com/nspcc-dev/neo-go/pkg/interop"
 	"github.com/nspcc-dev/neo-go/pkg/interop/iterator"
 	"github.com/nspcc-dev/neo-go/pkg/interop/native/crypto"
@@ -40,6 +39,9 @@ func _deploy(_ interface{}, isUpdate bool) {
 	if isUpdate {
 		return
 	}
+
+	// Total supply is read as int, missing value can not be converted
+	storage.Put(storage.GetContext(), totalSupplyKey, 0)
 }
 
 // GLOBAL PRIVATE METHODS FOR NFT
@@ -60,7 +62,7 @@ func getNFT(ctx storage.Context, token []byte) QuestionNFT {
 	var key = makeTokenKey(token)
 	var nft = storage.Get(ctx, key)
 	if nft == nil {
-		panic(fmt.Sprintf("Token with key:%s not found", key))
+		panic("Token with key:" + string(key) + " not found")
 	}
 
 	return std.Deserialize(nft.([]byte)).(QuestionNFT)
@@ -169,7 +171,7 @@ func TokensList() []string {
 
 func TokensOf(wallet interop.Hash160) iterator.Iterator {
 	if len(wallet) != 20 {
-		panic(fmt.Sprintf("Owner wallet:%s is not valid", wallet))
+		panic("Owner wallet:" + string(wallet) + " is not valid")
 	}
 	var ctx = storage.GetReadOnlyContext()
 	var key = makeAccountKey(wallet)
@@ -179,7 +181,7 @@ func TokensOf(wallet interop.Hash160) iterator.Iterator {
 
 func TokensOfList(wallet interop.Hash160) [][]byte {
 	if len(wallet) != 20 {
-		panic(fmt.Sprintf("Owner wallet:%s is not valid", wallet))
+		panic("Owner wallet:" + string(wallet) + " is not valid")
 	}
 	var ctx = storage.GetReadOnlyContext()
 	var key = makeAccountKey(wallet)
@@ -195,7 +197,7 @@ func TokensOfList(wallet interop.Hash160) [][]byte {
 
 func Transfer(to interop.Hash160, token []byte) bool {
 	if len(to) != 20 {
-		panic(fmt.Sprintf("To wallet:%s is not valid", to))
+		panic("To wallet:" + string(to) + " is not valid")
 	}
 	var ctx = storage.GetContext()
 	var nft = getNFT(ctx, token)
@@ -208,7 +210,7 @@ func Transfer(to interop.Hash160, token []byte) bool {
 
 	if !from.Equals(to) {
 		nft.Owner = to
-		nft.PrevOwners++
+		nft.PrevOwners += 1
 		setNFT(ctx, token, nft)
 
 		addToBalance(ctx, from, -1)
@@ -235,20 +237,32 @@ func Burn(token []byte) bool {
 	return true
 }
 
+// Function to find field of JSON data, the compiler does not support comma-ok index of map, and indexing by
+// missing key fails in NeoVM
+func findField(data map[string]any, key string) (any, bool) {
+	for k, v := range data {
+		if k == key {
+			return v, true
+		}
+	}
+
+	return nil, false
+}
+
 // data format '{"question":"What is Neo?", "data":"link<optional>"}'
 func parseData(input any) (string, string) {
 	var data = std.JSONDeserialize(input.([]byte)).(map[string]any)
 
 	var question = ""
-	if q, exists := data["question"]; exists {
-		question, _ = q.(string)
+	if q, exists := findField(data, "question"); exists {
+		question = q.(string)
 	} else {
 		panic("Missing or invalid 'question' field")
 	}
 
 	var sourceLink = ""
-	if l, exists := data["link"]; exists {
-		sourceLink, _ = l.(string)
+	if l, exists := findField(data, "link"); exists {
+		sourceLink = l.(string)
 	}
 
 	return question, sourceLink
@@ -1,76 +1,195 @@
 package room
 
 import (
-	"bytes"
-	"fmt"
-	"github.com/google/uuid"
 	"github.com/nspcc-dev/neo-go/pkg/interop"
 	"github.com/nspcc-dev/neo-go/pkg/interop/contract"
+	"github.com/nspcc-dev/neo-go/pkg/interop/iterator"
+	"github.com/nspcc-dev/neo-go/pkg/interop/native/crypto"
+	"github.com/nspcc-dev/neo-go/pkg/interop/native/gas"
+	"github.com/nspcc-dev/neo-go/pkg/interop/native/ledger"
+	"github.com/nspcc-dev/neo-go/pkg/interop/native/neo"
 	"github.com/nspcc-dev/neo-go/pkg/interop/native/std"
 	"github.com/nspcc-dev/neo-go/pkg/interop/runtime"
 	"github.com/nspcc-dev/neo-go/pkg/interop/storage"
-	"sort"
+	"github.com/nspcc-dev/neo-go/pkg/interop/util"
+
+	"contracts/rules"
 )
 
 // CONSTANTS
 
+// Statuses and refund policies are described in the rules package
+const (
+	StatusWaiting   = rules.StatusWaiting
+	StatusGaming    = rules.StatusGaming
+	StatusAnswering = rules.StatusAnswering
+	StatusRevealing = rules.StatusRevealing
+	StatusVoting    = rules.StatusVoting
+	StatusFinished  = rules.StatusFinished
+	StatusCancelled = rules.StatusCancelled
+)
+
 const (
-	StatusWaiting   = "waiting"   // Waiting for the game to start, players are joining
-	StatusGaming    = "gaming"    // In this phase, players are ready, but the question hasn't been asked yet
-	StatusAnswering = "answering" // Phase when the round has started and the question has been asked, players can submit answers
-	StatusVoting    = "voting"    // Voting phase, where players select the best answer from the options
-	StatusFinished  = "finished"  // Game is finished, and results have been determined
+	RefundFull     = rules.RefundFull
+	RefundMinusFee = rules.RefundMinusFee
 )
 
 const (
+	// Every method fails with this error when the acting wallet did not sign the transaction or has no rights for the action
+	errUnauthorized = "Unauthorized"
+
 	moneyContractKey = "m"
 	nftContractKey   = "n"
+	roomCounterKey   = "c" // Count of created rooms, used to derive room ids
+
+	roomIdLen     = 16                                // Bytes of sha256(counter + tx hash) used as room id, encoded with base58
+	saltLen       = 32                                // Fixed salt length of answer commitment, so salt and content can not be split differently
+	shortIdPrefix = "sid:"                            // sid:<shortId> -> roomId
+	shortIdChars  = "23456789abcdefghjkmnpqrstuvwxyz" // Alphabet of short ids without look-alike characters
+
+	// Room is stored under several keys, so every method reads and writes only the data it touches
+	roomPrefix      = "room:" // room:<roomId> -> Room
+	playerPrefix    = "pl:"   // pl:<roomId>:<wallet> -> Player
+	roundPrefix     = "rd:"   // rd:<roomId>:<round> -> Round
+	answerPrefix    = "an:"   // an:<roomId>:<round>:<answerIdx> -> Answer
+	answerIdxPrefix = "aw:"   // aw:<roomId>:<round>:<wallet> -> answerIdx
+	votePrefix      = "vt:"   // vt:<roomId>:<round>:<voter> -> indexes of answers voted by voter
+	tokenPrefix     = "tk:"   // tk:<roomId>:<tokenId> -> round, questions already asked in the room
+	bannedPrefix    = "bn:"   // bn:<roomId>:<wallet> -> true
+	allowedPrefix   = "al:"   // al:<roomId>:<wallet> -> true
+
+	statusIndexPrefix = "rs:" // rs:<status>:<roomId> -> roomId
+	hostIndexPrefix   = "rh:" // rh:<host><roomId> -> roomId
+	playerIndexPrefix = "rp:" // rp:<wallet><roomId> -> roomId
+
+	delegatePrefix = "dg:" // dg:<wallet><delegate> -> Delegate
+
+	maxListLimit = 100
+)
+
+// Fees and their bounds are set in whole tokens of the room currency and multiplied by the token unit,
+// e.g. 4 GAS or 4 NEO for creating the room
+const createRoomCommission = 4
+
+// Default room economic config, used for fields missing in config of the created room
+const (
+	defaultEntryFee      = 2
+	defaultAnswerFee     = 1
+	defaultHostShare     = 3000 // Host keeps 30% of the prize pool, the rest goes to winners
+	defaultGamePoolShare = 2000 // 20% of every round prize pool goes to game prize pool
+	defaultRemainderTo   = rules.RemainderHost
+)
+
+// Platform bounds for room economic config
+const (
+	maxEntryFee      = 100
+	maxAnswerFee     = 10
+	maxHostShare     = 5000
+	maxGamePoolShare = 5000
 )
 
+// Default phase timeouts in blocks, host can change them before players join
 const (
-	createRoomCommission    = 4_0000_0000
-	joinRoomCommission      = 2_0000_0000
-	sendAnswerCommission    = 1_0000_0000
-	gamePrizePoolCommission = 2000_0000
-	userCommission          = 7000_0000
-	oneGas                  = 1_0000_0000
+	defaultReadinessTimeout = 240 // Blocks for players to join and confirm readiness
+	defaultQuestionTimeout  = 80  // Blocks for host to ask next question
+	defaultAnsweringTimeout = 40  // Blocks for players to send answer commitments
+	defaultRevealTimeout    = 20  // Blocks for players to reveal answers
+	defaultVotingTimeout    = 40  // Blocks for players to vote
+	defaultAbandonGrace     = 240 // Blocks after missed deadline when players can abandon the room
+	maxPhaseTimeout         = 40_000
+)
+
+const (
+	defaultMinPlayers = 2
+	defaultMaxPlayers = 20
+	maxRoomPlayers    = 100
 )
 
 // STRUCTS
 
 type Room struct {
 	Id                string
+	ShortId           string // Short id to share with players, resolves to Id with ResolveRoom
 	Host              interop.Hash160
 	Status            string
+	Token             interop.Hash160 // Currency of the room, all commissions and rewards are paid in it
+	Commission        int             // Paid by host for creating the room, in fractions of the token
 	GamePrizePool     int
 	RoundPrizePool    int
 	RoundWinnersCount int
 	GameWinnersCount  int
-	Players           []Player
-	Rounds            []Round
+	RefundPolicy      string // RefundFull or RefundMinusFee, applied when player leaves or host cancels the room
+	CancellationFee   int
+	TiePolicy         string // Who wins when answers or players share the last winning place, see rules.Tie*
+	Config            RoomConfig
+	PlatformFee       int               // Platform fee of money contract in basis points when room was created
+	MinPlayers        int               // StartGame requires at least MinPlayers players
+	MaxPlayers        int               // Room rejects joining players when it is full
+	IsPrivate         bool              // Private room accepts only allowlisted wallets or wallets with invite signed by host
+	InviteKey         interop.PublicKey // Public key authorized by host to verify invite codes
+	Timeouts          PhaseTimeouts
+	PhaseDeadline     int // Block height after which anyone can advance the current phase
+	PlayersCount      int
+	ReadyCount        int // Players who confirmed readiness
+	FinishVotes       int // Players who voted to finish the game
+	RoundsCount       int
+}
+
+// RoomConfig is an economic config of the room, fees are set in fractions of the room token and shares in basis points
+type RoomConfig struct {
+	EntryFee      int    // Commission for joining the room
+	AnswerFee     int    // Commission for sending answer
+	HostShare     int    // Part of round and game prize pools kept by host
+	GamePoolShare int    // Part of round prize pool moved to game prize pool
+	RemainderTo   string // Who gets the part of prize pools left after integer division: host, platform or winner
+}
+
+// PhaseTimeouts is a count of blocks given for each phase of the room
+type PhaseTimeouts struct {
+	Readiness    int
+	Question     int
+	Answering    int
+	Reveal       int
+	Voting       int
+	AbandonGrace int // Blocks after phase deadline when host is considered to have abandoned the room
 }
 
 type Round struct {
-	TokenId  []byte // NFT token id, checking for uniqueness of questions
-	Question string
-	Answers  []Answer
+	TokenId      []byte // NFT token id, checking for uniqueness of questions
+	Question     string
+	AnswersCount int
 }
 
 type Answer struct {
-	Wallet  interop.Hash160
-	Content string
-	Votes   []interop.Hash160 // Wallets who voted for answer
+	Index      int // Index of answer in the round, used in VoteAnswer
+	Wallet     interop.Hash160
+	Commitment []byte // sha256(wallet + salt + content), sent while answering
+	Content    string // Empty until the answer is revealed
+	IsRevealed bool
+	Votes      int // Count of votes for answer
 }
 
 type Player struct {
 	Wallet          interop.Hash160
+	Paid            int // Join and answer commissions paid by player, used to refund abandoned room
 	RoundsWon       int
+	JoinedAt        int // Block height of joining, earlier player wins ties with TieEarliest policy
 	IsReady         bool
 	IsVotedToFinish bool
 	isActive        bool
 }
 
-// --data '{"m": "0xabc123...", "n": "0xdef456..."}'
+// Delegate is a session key allowed to play for the wallet in one room or until the block, so player does not sign
+// every move with the main wallet. Delegate can spend up to SpendLimit of the wallet's prepaid balance
+type Delegate struct {
+	Account    interop.Hash160
+	RoomId     string // Empty for every room
+	ExpiresAt  int    // Last block when delegate is valid, 0 for no expiration
+	SpendLimit int
+	Spent      int
+}
+
+// --data '{"m": "<base64 of money contract hash>", "n": "<base64 of nft contract hash>"}'
 func _deploy(data interface{}, isUpdate bool) {
 	if isUpdate {
 		return
@@ -78,17 +197,13 @@ func _deploy(data interface{}, isUpdate bool) {
 
 	var args = std.JSONDeserialize(data.([]byte)).(map[string]any)
 
-	var moneyContractHash interop.Hash160
-	if hash, exist := args[moneyContractKey]; exist && len(hash.(interop.Hash160)) == interop.Hash160Len {
-		moneyContractHash = hash.(interop.Hash160)
-	} else {
+	var moneyContractHash = deployHashField(args, moneyContractKey)
+	if moneyContractHash == nil {
 		panic("Missing or invalid 'm' field - money contract hash or invalid hash contract")
 	}
 
-	var nftContractHash interop.Hash160
-	if hash, exist := args[nftContractKey]; exist && len(hash.(interop.Hash160)) == interop.Hash160Len {
-		nftContractHash = hash.(interop.Hash160)
-	} else {
+	var nftContractHash = deployHashField(args, nftContractKey)
+	if nftContractHash == nil {
 		panic("Missing or invalid 'n' field - nft contract hash or invalid hash contract")
 	}
 
@@ -99,537 +214,2004 @@ func _deploy(data interface{}, isUpdate bool) {
 
 // GLOBAL PRIVATE METHODS FOR ROOM
 
-func getSender() interop.Hash160 {
-	return runtime.GetScriptContainer().Sender
+// Function to read contract hash from deploy data, JSON strings can not carry raw bytes, so hash is base64 encoded
+func deployHashField(args map[string]any, key string) interop.Hash160 {
+	var value = ""
+	if v, exists := findField(args, key); exists {
+		value = v.(string)
+	}
+
+	var hash = std.Base64Decode([]byte(value))
+	if len(hash) != interop.Hash160Len {
+		return nil
+	}
+	return interop.Hash160(hash)
+}
+
+// Function to check that the acting wallet signed the transaction. Wallet can be a contract account, then the contract
+// must call the room contract itself
+func authorize(wallet interop.Hash160) {
+	if !runtime.CheckWitness(wallet) {
+		panic(errUnauthorized)
+	}
+}
+
+// Function to check that the acting wallet is the host of the room and signed the transaction
+func authorizeHost(room *Room, host interop.Hash160) {
+	if !room.Host.Equals(host) {
+		panic(errUnauthorized)
+	}
+	authorize(host)
+}
+
+// Function to make room ids from the room counter and the hash of the current transaction,
+// so ids are the same on every node and do not depend on OS randomness.
+// The first id is a base58 of 16 bytes of sha256, the second is a short id to share with players
+func makeRoomIds(ctx storage.Context) (string, string) {
+	var counter = 0
+	if data := storage.Get(ctx, roomCounterKey); data != nil {
+		counter = data.(int)
+	}
+	counter++
+	storage.Put(ctx, roomCounterKey, counter)
+
+	var tx = runtime.GetScriptContainer()
+	var seed = append([]byte(std.Itoa10(counter)+":"), tx.Hash...)
+	var hash = crypto.Sha256(seed)
+
+	return std.Base58Encode(hash[:roomIdLen]), makeShortId(counter)
+}
+
+// Function to encode room counter with short id alphabet, e.g. 1000 -> "33a"
+func makeShortId(counter int) string {
+	var base = len(shortIdChars)
+	var result = []byte{}
+	for counter > 0 {
+		result = append([]byte{shortIdChars[counter%base]}, result...)
+		counter /= base
+	}
+
+	return string(result)
+}
+
+// Function to get full room id by short id or by full id, returns empty string if room was not found
+func resolveRoomId(ctx storage.Context, roomId string) string {
+	if storage.Get(ctx, roomPrefix+roomId) != nil {
+		return roomId
+	}
+
+	var id = storage.Get(ctx, shortIdPrefix+roomId)
+	if id == nil {
+		return ""
+	}
+
+	return id.(string)
+}
+
+// Function to get room by short id or by full id, used by read-only methods
+func getRoomByAnyId(ctx storage.Context, roomId string) *Room {
+	var id = resolveRoomId(ctx, roomId)
+	if id == "" {
+		panic("Room with roomId=" + roomId + " not found")
+	}
+
+	return getRoom(ctx, id)
 }
 
-// Function to set room in storage with serialize
+// Function to set room in storage with serialize, players and rounds are stored under their own keys
 func setRoom(ctx storage.Context, room *Room) {
 	var serializedRoom = std.Serialize(room)
-	storage.Put(ctx, "room:"+room.Id, serializedRoom)
+	storage.Put(ctx, roomPrefix+room.Id, serializedRoom)
 }
 
-// Function to get room from storage with deserialize
-func getRoom(ctx storage.Context, roomId string) Room {
-	var roomData = storage.Get(ctx, "room:"+roomId)
+// Function to get room from storage with deserialize. Compiler takes address only of struct literals and skips
+// assignment through pointer, so deserialized room is copied into a literal, new fields of Room must be added here
+func getRoom(ctx storage.Context, roomId string) *Room {
+	var roomData = storage.Get(ctx, roomPrefix+roomId)
 
 	if roomData == nil {
-		panic(fmt.Sprintf("Room with roomId=%s not found", roomId))
+		panic("Room with roomId=" + roomId + " not found")
 	}
 
-	var room = std.Deserialize(roomData.([]byte)).(Room)
-	return room
+	var data = std.Deserialize(roomData.([]byte)).(Room)
+	return &Room{
+		Id:                data.Id,
+		ShortId:           data.ShortId,
+		Host:              data.Host,
+		Status:            data.Status,
+		Token:             data.Token,
+		Commission:        data.Commission,
+		GamePrizePool:     data.GamePrizePool,
+		RoundPrizePool:    data.RoundPrizePool,
+		RoundWinnersCount: data.RoundWinnersCount,
+		GameWinnersCount:  data.GameWinnersCount,
+		RefundPolicy:      data.RefundPolicy,
+		CancellationFee:   data.CancellationFee,
+		TiePolicy:         data.TiePolicy,
+		Config:            data.Config,
+		PlatformFee:       data.PlatformFee,
+		MinPlayers:        data.MinPlayers,
+		MaxPlayers:        data.MaxPlayers,
+		IsPrivate:         data.IsPrivate,
+		InviteKey:         data.InviteKey,
+		Timeouts:          data.Timeouts,
+		PhaseDeadline:     data.PhaseDeadline,
+		PlayersCount:      data.PlayersCount,
+		ReadyCount:        data.ReadyCount,
+		FinishVotes:       data.FinishVotes,
+		RoundsCount:       data.RoundsCount,
+	}
 }
 
-func getNftContractHash(ctx storage.Context) interop.Hash160 {
-	return storage.Get(ctx, nftContractKey).(interop.Hash160)
+func makePlayerKey(roomId string, wallet interop.Hash160) []byte {
+	return append([]byte(playerPrefix+roomId+":"), wallet...)
 }
 
-func getMoneyContractHash(ctx storage.Context) interop.Hash160 {
-	return storage.Get(ctx, moneyContractKey).(interop.Hash160)
+func makeRoundKey(roomId string, round int) []byte {
+	return []byte(roundPrefix + roomId + ":" + std.Itoa10(round))
 }
 
-// Function to send message to players, event is recorded in blockchain
-// Could be read through getapplicationlog or RPC call
-func sendMessageToPlayers(notificationName string, message string) {
-	runtime.Notify(notificationName, message)
+func makeAnswerKey(roomId string, round int, answerIdx int) []byte {
+	return []byte(answerPrefix + roomId + ":" + std.Itoa10(round) + ":" + std.Itoa10(answerIdx))
 }
 
-func isPlayerDeactivate(room Room, wallet interop.Hash160) bool {
-	for _, player := range room.Players {
-		if player.Wallet.Equals(wallet) {
-			return !player.isActive
-		}
+func makeAnswerIdxKey(roomId string, round int, wallet interop.Hash160) []byte {
+	return append([]byte(answerIdxPrefix+roomId+":"+std.Itoa10(round)+":"), wallet...)
+}
+
+func makeVoteKey(roomId string, round int, voter interop.Hash160) []byte {
+	return append([]byte(votePrefix+roomId+":"+std.Itoa10(round)+":"), voter...)
+}
+
+// Token id is sha256 of the question, first 20 bytes are used to fit into storage key length limit
+func makeTokenKey(roomId string, tokenId []byte) []byte {
+	if len(tokenId) > interop.Hash160Len {
+		tokenId = tokenId[:interop.Hash160Len]
 	}
+	return append([]byte(tokenPrefix+roomId+":"), tokenId...)
+}
 
-	return true // Player was not found
+func makeBannedKey(roomId string, wallet interop.Hash160) []byte {
+	return append([]byte(bannedPrefix+roomId+":"), wallet...)
 }
 
-func sendRewardRoundWinners(ctx storage.Context, room *Room, wonAnswers []Answer) {
-	var pool = room.RoundPrizePool
-	room.GamePrizePool += pool * gamePrizePoolCommission / oneGas // Increase GamePrizePool by 20% of the RoundPrizePool
-	pool -= pool * gamePrizePoolCommission / oneGas
+func makeAllowedKey(roomId string, wallet interop.Hash160) []byte {
+	return append([]byte(allowedPrefix+roomId+":"), wallet...)
+}
 
-	/*
-		Total votes = 9 4 1 1 = 15
-		Weights to send reward = 9/15 4/15 1/15 1/15, all * userCommission
-		because 1 - userCommission is commission of host for game
-	*/
-	var totalVotes = 0
-	for _, answer := range wonAnswers {
-		totalVotes += len(answer.Votes)
+// Function to get player of the room, second value is false if player was not found
+func getPlayer(ctx storage.Context, roomId string, wallet interop.Hash160) (Player, bool) {
+	var data = storage.Get(ctx, makePlayerKey(roomId, wallet))
+	if data == nil {
+		return Player{}, false
 	}
 
-	if totalVotes == 0 {
-		runtime.Log("No votes, skipping reward distribution")
-		return
+	return std.Deserialize(data.([]byte)).(Player), true
+}
+
+func setPlayer(ctx storage.Context, roomId string, player Player) {
+	storage.Put(ctx, makePlayerKey(roomId, player.Wallet), std.Serialize(player))
+}
+
+func deletePlayer(ctx storage.Context, roomId string, wallet interop.Hash160) {
+	storage.Delete(ctx, makePlayerKey(roomId, wallet))
+	storage.Delete(ctx, makePlayerIndexKey(wallet, roomId))
+}
+
+// Function to get all players of the room ordered by wallet
+func getPlayers(ctx storage.Context, roomId string) []Player {
+	var iter = storage.Find(ctx, []byte(playerPrefix+roomId+":"), storage.ValuesOnly)
+
+	var players = []Player{}
+	for iterator.Next(iter) {
+		players = append(players, std.Deserialize(iterator.Value(iter).([]byte)).(Player))
 	}
 
-	for _, answer := range wonAnswers {
-		// reward = pool * weight * userCommission / oneGas
-		var reward = (pool * (len(answer.Votes) / totalVotes) * userCommission) / oneGas
+	return players
+}
 
-		var result = contract.Call(getMoneyContractHash(ctx), "RewardPlayer", contract.All, answer.Wallet, reward).(bool)
-		sendMessageToPlayers(
-			"RewardResult",
-			fmt.Sprintf("player:%s, rewarded:%t", string(answer.Wallet), result))
+func getRound(ctx storage.Context, roomId string, idx int) Round {
+	var data = storage.Get(ctx, makeRoundKey(roomId, idx))
+	if data == nil {
+		panic("Round with idx=" + std.Itoa10(idx) + " not found")
 	}
 
-	// After receiving all the rewards, the remaining part of the pool remains with the host.
-	// Reward for host = pool - pool * 70% (and the integer precision inaccuracy)
-	room.RoundPrizePool = 0
-	setRoom(ctx, room)
+	return std.Deserialize(data.([]byte)).(Round)
 }
 
-func sendRewardGameWinners(ctx storage.Context, room *Room, wonPlayers []Player) {
-	var pool = room.GamePrizePool
-	var totalRounds = len(room.Rounds)
+func setRound(ctx storage.Context, roomId string, idx int, round Round) {
+	storage.Put(ctx, makeRoundKey(roomId, idx), std.Serialize(round))
+}
 
-	if totalRounds == 0 {
-		runtime.Log("No rounds played, skipping reward distribution")
-		return
-	}
+func getAnswer(ctx storage.Context, roomId string, round int, idx int) Answer {
+	return std.Deserialize(storage.Get(ctx, makeAnswerKey(roomId, round, idx)).([]byte)).(Answer)
+}
 
-	for _, player := range wonPlayers {
-		// reward = pool * weight * userCommission / oneGas
-		var reward = (pool * (player.RoundsWon / totalRounds) * userCommission) / oneGas
+func setAnswer(ctx storage.Context, roomId string, round int, answer Answer) {
+	storage.Put(ctx, makeAnswerKey(roomId, round, answer.Index), std.Serialize(answer))
+}
 
-		var result = contract.Call(getMoneyContractHash(ctx), "RewardPlayer", contract.All, player.Wallet, reward).(bool)
-		sendMessageToPlayers(
-			"RewardResult",
-			fmt.Sprintf("player:%s, rewarded:%t", string(player.Wallet), result))
+// Function to get index of the player's answer in the round, -1 if player did not answer
+func getAnswerIdx(ctx storage.Context, roomId string, round int, wallet interop.Hash160) int {
+	var idx = storage.Get(ctx, makeAnswerIdxKey(roomId, round, wallet))
+	if idx == nil {
+		return -1
 	}
 
-	// After receiving all the rewards, the remaining part of the pool remains with the host.
-	// Reward for host = pool - pool * 70% (and the integer precision inaccuracy)
-	room.GamePrizePool = 0
-	setRoom(ctx, room)
+	return idx.(int)
 }
 
-// MAIN METHODS TO PLAY IN GAME
+func getAnswers(ctx storage.Context, roomId string, round int, count int) []Answer {
+	var answers = []Answer{}
+	for i := 0; i < count; i++ {
+		answers = append(answers, getAnswer(ctx, roomId, round, i))
+	}
 
-func CreateRoom(RoundWinnersCount int, GameWinnersCount int) string {
-	var ctx = storage.GetContext()
-	var id = uuid.NewString()
-	var host = getSender()
+	return answers
+}
 
-	var withdraw = contract.Call(getMoneyContractHash(ctx), "Deposit", contract.All, host, createRoomCommission).(bool)
-	if !withdraw {
-		panic("Host does not have enough tokens to create a room")
+// Function to check that player has revealed answer in the round
+func hasRevealedAnswer(ctx storage.Context, roomId string, round int, wallet interop.Hash160) bool {
+	var idx = getAnswerIdx(ctx, roomId, round, wallet)
+	if idx < 0 {
+		return false
 	}
 
-	var room = Room{
-		Id:                id,
-		Host:              host,
-		Status:            StatusWaiting,
-		GamePrizePool:     createRoomCommission,
-		RoundPrizePool:    0,
-		RoundWinnersCount: RoundWinnersCount,
-		GameWinnersCount:  GameWinnersCount,
-		Players:           []Player{},
-		Rounds:            []Round{},
+	return getAnswer(ctx, roomId, round, idx).IsRevealed
+}
+
+func getNftContractHash(ctx storage.Context) interop.Hash160 {
+	return storage.Get(ctx, nftContractKey).(interop.Hash160)
+}
+
+func getMoneyContractHash(ctx storage.Context) interop.Hash160 {
+	return storage.Get(ctx, moneyContractKey).(interop.Hash160)
+}
+
+// Function to get count of blocks given for the status
+func phaseTimeout(timeouts PhaseTimeouts, status string) int {
+	switch status {
+	case StatusWaiting:
+		return timeouts.Readiness
+	case StatusGaming:
+		return timeouts.Question
+	case StatusAnswering:
+		return timeouts.Answering
+	case StatusRevealing:
+		return timeouts.Reveal
+	case StatusVoting:
+		return timeouts.Voting
 	}
 
-	setRoom(ctx, &room)
-	return id
+	return 0
 }
 
-func JoinRoom(roomId string) bool {
-	var ctx = storage.GetContext()
-	var room = getRoom(ctx, roomId)
-	var wallet = getSender()
+func makeStatusIndexKey(status string, roomId string) []byte {
+	return []byte(statusIndexPrefix + status + ":" + roomId)
+}
 
-	if room.Host.Equals(wallet) || room.Status != StatusWaiting {
-		return false // Host can not be player, player cannot join started room
+func makeHostIndexKey(host interop.Hash160, roomId string) []byte {
+	return append(append([]byte(hostIndexPrefix), host...), []byte(roomId)...)
+}
+
+func makePlayerIndexKey(wallet interop.Hash160, roomId string) []byte {
+	return append(append([]byte(playerIndexPrefix), wallet...), []byte(roomId)...)
+}
+
+// Function to move room to the next status, start deadline of the new phase and update status index
+func setPhase(ctx storage.Context, room *Room, status string) {
+	if isRoomClosed(room) {
+		panic("Room is already '" + room.Status + "'") // Closed room can not restart or repeat its last phase
+	}
+	if room.Status != status && !rules.CanTransition(room.Status, status) {
+		panic("Room can not move from '" + room.Status + "' to '" + status + "'")
 	}
 
-	for _, player := range room.Players {
-		if player.Wallet.Equals(wallet) {
-			return false // Player already joined room
+	if room.Status != status {
+		if room.Status != "" {
+			storage.Delete(ctx, makeStatusIndexKey(room.Status, room.Id))
 		}
+		storage.Put(ctx, makeStatusIndexKey(status, room.Id), room.Id)
 	}
 
-	var withdraw = contract.Call(getMoneyContractHash(ctx), "Deposit", contract.All, wallet, joinRoomCommission).(bool)
-	if !withdraw {
-		panic("Player does not have enough tokens to join in room")
+	room.Status = status
+	if isRoomClosed(room) {
+		room.PhaseDeadline = 0
+	} else {
+		room.PhaseDeadline = ledger.CurrentIndex() + phaseTimeout(room.Timeouts, status)
 	}
-	room.GamePrizePool += joinRoomCommission
 
-	var player = Player{
-		Wallet:          wallet,
-		RoundsWon:       0,
-		IsReady:         false,
-		IsVotedToFinish: false,
-		isActive:        true,
-	}
+	// Events are recorded in blockchain, could be read through getapplicationlog or RPC call.
+	// Every event of the room has roomId as the first argument, events are declared in room_contract.yml
+	runtime.Notify("PhaseChanged", room.Id, status, room.PhaseDeadline)
+}
 
-	room.Players = append(room.Players, player)
-	setRoom(ctx, &room)
-	return true
+func isRoomClosed(room *Room) bool {
+	return rules.IsClosed(room.Status)
 }
 
-func ConfirmReadiness(roomId string) bool {
-	var ctx = storage.GetContext()
-	var room = getRoom(ctx, roomId)
-	var wallet = getSender()
-
-	for i, p := range room.Players {
-		if p.Wallet.Equals(wallet) {
-			if p.IsReady || !p.isActive {
-				return false // Player is already ready, player must be active
-			}
-			room.Players[i].IsReady = true
-			setRoom(ctx, &room)
-			return true
-		}
+func isPlayerDeactivate(ctx storage.Context, roomId string, wallet interop.Hash160) bool {
+	var player, found = getPlayer(ctx, roomId, wallet)
+	if !found {
+		return true // Player was not found
 	}
 
-	return false // Player not found in the room
+	return !player.isActive
 }
 
-func StartGame(roomId string) bool {
-	var ctx = storage.GetContext()
-	var room = getRoom(ctx, roomId)
-
-	if !room.Host.Equals(getSender()) || room.Status != StatusWaiting || len(room.Players) <= room.RoundWinnersCount {
-		return false // Only host can start game, room status must be waiting and players count must be > count winners
+// Function to get shares of the room pools
+func roomShares(room *Room) rules.Shares {
+	return rules.Shares{
+		Platform: room.PlatformFee,
+		GamePool: room.Config.GamePoolShare,
+		Host:     room.Config.HostShare,
 	}
+}
 
-	for _, player := range room.Players {
-		if !player.IsReady {
-			return false // If any player is not ready, the game can not be started
-		}
+// Function to move part of the room escrow to settled earnings of the host and the platform,
+// only settled earnings can be withdrawn from money contract
+func settleEarnings(ctx storage.Context, room *Room, hostAmount int, platformAmount int) {
+	if hostAmount == 0 && platformAmount == 0 {
+		return
 	}
 
-	room.Status = StatusGaming
-	setRoom(ctx, &room)
-	return true
+	var result = contract.Call(getMoneyContractHash(ctx), "settle", contract.All, room.Id, room.Host, hostAmount, platformAmount).(bool)
+	if !result {
+		panic("Failed to settle room earnings")
+	}
 }
 
-func checkingForUniqueness(rounds []Round, tokenId []byte) bool {
-	for _, round := range rounds {
-		if bytes.Equal(round.TokenId, tokenId) {
-			return false
+// Function to credit rewards of the payout to claimable balances of winners and record every line of it in PayoutBreakdown event.
+// Host and platform parts are settled on the money contract, game pool part stays in the room escrow and is added by the caller
+func sendPayout(ctx storage.Context, room *Room, source string, payout rules.Payout, wallets []interop.Hash160) {
+	// Every line is [recipient, wallet, amount], recipient is "winner", "host", "gamePool" or "platform"
+	var lines = []any{}
+	for i, reward := range payout.Rewards {
+		var result = contract.Call(getMoneyContractHash(ctx), "rewardPlayer", contract.All, room.Id, wallets[i], reward).(bool)
+		if !result {
+			panic("Failed to reward winner") // Pools must not be reset when the reward stays in the escrow
 		}
+		runtime.Notify("RewardResult", room.Id, wallets[i], reward, result)
+		lines = append(lines, []any{"winner", wallets[i], reward})
 	}
+	lines = append(lines, []any{"host", room.Host, payout.Host})
+	lines = append(lines, []any{"gamePool", nil, payout.GamePool})
+	lines = append(lines, []any{"platform", nil, payout.Platform})
+	settleEarnings(ctx, room, payout.Host, payout.Platform)
 
-	return true
+	runtime.Notify("PayoutBreakdown", room.Id, source, payout.Pool, payout.Remainder, payout.RemainderTo, lines)
 }
 
-func AskQuestion(roomId string, tokenId []byte) bool {
-	var ctx = storage.GetContext()
-	var room = getRoom(ctx, roomId)
-	var wallet = getSender()
-
-	if !room.Host.Equals(wallet) || room.Status != StatusGaming {
-		return false // Only host can ask question, room status must be gaming
-	}
-
-	// Get token properties from nft contract
-	var tokenProperties = contract.Call(getNftContractHash(ctx), "Properties", contract.All, tokenId).(map[string]string)
-	if tokenProperties == nil || tokenProperties["owner"] != string(wallet) || checkingForUniqueness(room.Rounds, tokenId) {
-		return false // NFT was not found, host is not the owner of question, round must contain unique questions
+func sendRewardRoundWinners(ctx storage.Context, room *Room, answers []Answer, winners []rules.Winner) {
+	/*
+		Total votes = 9 4 1 1 = 15
+		Weights to send reward = 9/15 4/15 1/15 1/15 of the pool without platform, game pool and host shares
+	*/
+	var totalVotes = 0
+	var wallets = []interop.Hash160{}
+	for _, winner := range winners {
+		totalVotes += winner.Score
+		wallets = append(wallets, answers[winner.Index].Wallet)
 	}
 
-	var question = tokenProperties["question"]
-	var round = Round{
-		TokenId:  tokenId,
-		Question: question,
-		Answers:  []Answer{},
+	if totalVotes == 0 {
+		runtime.Log("No votes, skipping reward distribution") // Round prize pool moves to the next round
+		return
 	}
-	room.Rounds = append(room.Rounds, round)
-	room.Status = StatusAnswering
 
-	sendMessageToPlayers("RoundQuestion", question)
+	var payout = rules.RoundPayout(room.RoundPrizePool, roomShares(room), winners, room.Config.RemainderTo)
+	sendPayout(ctx, room, "round", payout, wallets)
 
-	setRoom(ctx, &room)
-	return true
+	room.GamePrizePool += payout.GamePool // Increase GamePrizePool by game pool share of the RoundPrizePool
+	room.RoundPrizePool = 0
+	setRoom(ctx, room)
 }
 
-func roomContainsPlayer(players []Player, wallet interop.Hash160) bool {
+// Function to count rounds won by all players, round can have several winners
+func countRoundsWon(players []Player) int {
+	var totalWins = 0
 	for _, player := range players {
-		if player.Wallet.Equals(wallet) {
-			return true
-		}
+		totalWins += player.RoundsWon
 	}
-	return false
-}
 
-func SendAnswer(roomId string, text string) bool {
-	var ctx = storage.GetContext()
-	var room = getRoom(ctx, roomId)
-	var wallet = getSender()
+	return totalWins
+}
 
-	if !roomContainsPlayer(room.Players, wallet) || isPlayerDeactivate(room, wallet) || room.Status != StatusAnswering {
-		return false // Only player can send content, player must be active, room status must be answering
+func sendRewardGameWinners(ctx storage.Context, room *Room, players []Player, winners []rules.Winner) {
+	var totalWins = countRoundsWon(players)
+	if totalWins == 0 {
+		runtime.Log("No rounds won, skipping reward distribution")
+		return
 	}
 
-	var withdraw = contract.Call(getMoneyContractHash(ctx), "Deposit", contract.All, wallet, sendAnswerCommission).(bool)
-	if !withdraw {
-		panic("Player does not have enough tokens to send answer")
+	var wallets = []interop.Hash160{}
+	for _, winner := range winners {
+		wallets = append(wallets, players[winner.Index].Wallet)
 	}
-	room.RoundPrizePool += sendAnswerCommission
 
-	var round = room.Rounds[len(room.Rounds)-1]
+	var payout = rules.GamePayout(room.GamePrizePool, roomShares(room), winners, totalWins, room.Config.RemainderTo)
+	sendPayout(ctx, room, "game", payout, wallets)
 
-	for _, answer := range round.Answers {
-		if answer.Wallet.Equals(wallet) {
-			return false // Player cannot send answer twice
-		}
-	}
+	room.GamePrizePool = 0
+	setRoom(ctx, room)
+}
 
-	var answer = Answer{
-		Wallet:  wallet,
-		Content: text,
-		Votes:   []interop.Hash160{},
+// Function to find field of JSON data, the compiler does not support comma-ok index of map, and indexing by
+// missing key fails in NeoVM
+func findField(data map[string]any, key string) (any, bool) {
+	for k, v := range data {
+		if k == key {
+			return v, true
+		}
 	}
 
-	round.Answers = append(round.Answers, answer)
-	room.Rounds[len(room.Rounds)-1] = round
-	setRoom(ctx, &room)
-	return true
+	return nil, false
 }
 
-func deactivatingPlayers(rounds []Round, players []Player) []Player {
-	var previous, current = rounds[len(rounds)-2], rounds[len(rounds)-1]
-	for _, player := range players {
-		var isActive = false
-		for _, answer := range previous.Answers {
-			if answer.Wallet.Equals(player.Wallet) {
-				isActive = true
-				break
-			}
-		}
+// Function to read int field of room config, missing field is replaced with default value
+func configField(data map[string]any, key string, defaultValue int, maxValue int) int {
+	var value = defaultValue
+	if v, exists := findField(data, key); exists {
+		value = v.(int)
+	}
 
-		for _, answer := range current.Answers {
-			if answer.Wallet.Equals(player.Wallet) {
-				isActive = true
-			}
-		}
-		player.isActive = isActive
+	if value < 0 || value > maxValue {
+		panic("Invalid '" + key + "' field - value must be in [0, " + std.Itoa10(maxValue) + "]")
 	}
 
-	return players
+	return value
 }
 
-func EndQuestion(roomId string) bool {
-	var ctx = storage.GetContext()
-	var room = getRoom(ctx, roomId)
+// Function to read recipient of the payout remainder from room config
+func remainderField(data map[string]any) string {
+	var value = defaultRemainderTo
+	if v, exists := findField(data, "remainderTo"); exists {
+		value = v.(string)
+	}
 
-	if !room.Host.Equals(getSender()) || room.Status != StatusAnswering {
-		return false // Only host can end question, room status must be answering
+	if !rules.IsRemainderRecipient(value) {
+		panic("Invalid 'remainderTo' field - value must be host, platform or winner")
 	}
 
-	room.Status = StatusVoting
+	return value
+}
 
-	var rounds = room.Rounds
-	var round = rounds[len(rounds)-1]
-	var result string
-	for i, answer := range round.Answers {
-		result += fmt.Sprintf("index:%d, player:%s, answer:%s\n", i, answer.Wallet, answer.Content)
+// Function to read token of the room from room config: "GAS", "NEO" or base64 of the token script hash, GAS by default
+func tokenField(data map[string]any) interop.Hash160 {
+	var value = "GAS"
+	if v, exists := findField(data, "token"); exists {
+		value = v.(string)
 	}
-	sendMessageToPlayers("RoundAnswers", result)
 
-	if len(rounds) > 1 {
-		room.Players = deactivatingPlayers(rounds, room.Players)
+	switch value {
+	case "GAS":
+		return interop.Hash160(gas.Hash)
+	case "NEO":
+		return interop.Hash160(neo.Hash)
 	}
 
-	setRoom(ctx, &room)
-	return true
+	var token = std.Base64Decode([]byte(value))
+	if len(token) != interop.Hash160Len {
+		panic("Invalid 'token' field - value must be GAS, NEO or base64 of the token hash")
+	}
+	return interop.Hash160(token)
 }
 
-func VoteAnswer(roomId string, answerIdx int) bool {
-	var ctx = storage.GetContext()
-	var room = getRoom(ctx, roomId)
-	var wallet = getSender()
+// Function to get one whole token in its fractions
+func tokenUnit(token interop.Hash160) int {
+	var decimals = contract.Call(token, "decimals", contract.ReadStates).(int)
 
-	if room.Host.Equals(wallet) || isPlayerDeactivate(room, wallet) || room.Status != StatusVoting {
-		return false // Only player can choose answer, player must be active, room status must be voting
+	var unit = 1
+	for i := 0; i < decimals; i++ {
+		unit *= 10
 	}
+	return unit
+}
 
-	var round = room.Rounds[len(room.Rounds)-1]
-	if !(0 <= answerIdx && answerIdx < len(round.Answers)) || round.Answers[answerIdx].Wallet.Equals(wallet) {
-		return false // answerIdx is incorrect and player cannot vote for himself
+// data format '{"entryFee":200000000, "answerFee":100000000, "hostShare":3000, "gamePoolShare":2000, "remainderTo":"host"}', all fields
+// are optional, fees are set in fractions of the room token and shares in basis points. Unit is one whole token
+func parseRoomConfig(data map[string]any, unit int) RoomConfig {
+	if data == nil {
+		data = map[string]any{}
 	}
 
-	for _, votedWallet := range round.Answers[answerIdx].Votes {
-		if votedWallet.Equals(wallet) {
-			return false // Player cannot vote twice for one answer
-		}
+	return RoomConfig{
+		EntryFee:      configField(data, "entryFee", defaultEntryFee*unit, maxEntryFee*unit),
+		AnswerFee:     configField(data, "answerFee", defaultAnswerFee*unit, maxAnswerFee*unit),
+		HostShare:     configField(data, "hostShare", defaultHostShare, maxHostShare),
+		GamePoolShare: configField(data, "gamePoolShare", defaultGamePoolShare, maxGamePoolShare),
+		RemainderTo:   remainderField(data),
 	}
+}
 
-	round.Answers[answerIdx].Votes = append(round.Answers[answerIdx].Votes, wallet)
-	setRoom(ctx, &room)
-	return true
+// Function to move paid fee from the room contract to the room escrow in money contract
+func depositFee(ctx storage.Context, roomId string, token interop.Hash160, fee int) {
+	if fee == 0 {
+		return
+	}
+
+	var self = runtime.GetExecutingScriptHash()
+	if !contract.Call(token, "transfer", contract.All, self, getMoneyContractHash(ctx), fee, roomId).(bool) {
+		panic("Failed to deposit fee to the room escrow")
+	}
 }
 
-func chooseWonAnswers(round Round, RoundWinnersCount int) []Answer {
-	var wonAnswers []Answer
-	var answers = round.Answers
-	sort.Slice(answers, func(a, b int) bool {
-		return len(answers[a].Votes) > len(answers[b].Votes)
-	})
+// Function to charge fee from the wallet's prepaid balance to the room escrow in money contract
+func chargeFee(ctx storage.Context, roomId string, wallet interop.Hash160, fee int) {
+	if fee == 0 {
+		return
+	}
 
-	if RoundWinnersCount > len(round.Answers) {
-		return round.Answers
+	var result = contract.Call(getMoneyContractHash(ctx), "charge", contract.All, roomId, wallet, fee).(bool)
+	if !result {
+		panic("Not enough prepaid balance or room spending cap is reached")
 	}
+}
 
-	if RoundWinnersCount == 1 {
-		return []Answer{answers[0]}
+// Function to read bytes field of payment data, bytes are passed in base64
+func bytesField(data map[string]any, key string) []byte {
+	if v, exists := findField(data, key); exists && v.(string) != "" {
+		return std.Base64Decode([]byte(v.(string)))
 	}
 
-	// Choose wonAnswers from sorted answers. If the current answer has the same number of votes as the previous one,
-	// we add it to the wonAnswers list. We increase the number of wonAnswers if there are multiple answers with the same
-	// number of votes, as in cases where there are 5 answers with equal votes and RoundWinnersCount is 3, all should be included.
-	var lastVote = len(answers[0].Votes)
-	wonAnswers = append(wonAnswers, answers[0])
-	for i := 1; i < len(answers) && len(wonAnswers) < RoundWinnersCount; i++ {
-		var currentVote = len(answers[i].Votes)
-		if lastVote == currentVote {
-			RoundWinnersCount++ // todo: Могут возникнуть проблемы, надо протестировать
-		}
-		lastVote = currentVote
-		wonAnswers = append(wonAnswers, answers[i])
+	return []byte{}
+}
+
+// Function to check that payment covers the fee of the action
+func checkPayment(amount int, fee int) {
+	if amount < fee {
+		panic("Payment must be at least " + std.Itoa10(fee))
 	}
+}
 
-	return wonAnswers
+// Function to check that payment is made in the token of the room
+func checkToken(room *Room, token interop.Hash160) {
+	if !room.Token.Equals(token) {
+		panic("Payment must be made in the token of the room")
+	}
 }
 
-func GetRoundWinner(roomId string) bool {
-	var ctx = storage.GetContext()
-	var room = getRoom(ctx, roomId)
+// OnNEP17Payment is the entry point of paid actions, player transfers tokens to the room contract with action in data:
+//
+//	'{"action":"create", "roundWinners":1, "gameWinners":1, "config":{...}}' - room creation commission
+//	'{"action":"join", "room":"<id>", "invite":"<base64>"}' - room's entryFee, invite is optional
+//	'{"action":"answer", "room":"<id>", "commitment":"<base64>"}' - room's answerFee
+//
+// Room is created in the transferred token, join and answer must be paid in the token of the room.
+// Room can be set by full or short id. Fee goes to the room escrow in money contract, overpayment is sent back.
+// Payment is rejected when the action fails, so tokens stay in the player's wallet
+func OnNEP17Payment(from interop.Hash160, amount int, data any) {
+	defer func() {
+		if r := recover(); r != nil {
+			runtime.Log(r.(string))
+			util.Abort()
+		}
+	}()
 
-	if !room.Host.Equals(getSender()) || room.Status != StatusVoting {
-		return false // Only host can get winner, room status must be voting
+	var token = runtime.GetCallingScriptHash()
+	if from == nil && token.Equals(gas.Hash) {
+		return // GAS generated by NEO passing through the contract
 	}
 
-	var round = room.Rounds[len(room.Rounds)-1]
-	if len(round.Answers) == 0 {
-		return false // Zero winners, because no answer
+	if len(from) != interop.Hash160Len {
+		panic("Only transfers from wallets are accepted")
 	}
 
-	var wonAnswers = chooseWonAnswers(round, room.RoundWinnersCount)
-	var players = room.Players
-	for _, answer := range wonAnswers {
-		for _, player := range players {
-			if answer.Wallet.Equals(player.Wallet) {
-				player.RoundsWon++
-				break
-			}
+	var ctx = storage.GetContext()
+	var args = std.JSONDeserialize(data.([]byte)).(map[string]any)
+
+	var fee = 0
+	var roomId = ""
+	switch args["action"] {
+	case "create":
+		fee = createRoomCommission * tokenUnit(token)
+		checkPayment(amount, fee)
+
+		var config = map[string]any{}
+		if v, exists := findField(args, "config"); exists {
+			config = v.(map[string]any)
+		}
+		roomId = createRoom(ctx, from, token, args["roundWinners"].(int), args["gameWinners"].(int), config)
+	case "join":
+		var room = getRoom(ctx, resolveRoomId(ctx, args["room"].(string)))
+		fee = room.Config.EntryFee
+		roomId = room.Id
+		checkToken(room, token)
+		checkPayment(amount, fee)
+
+		if !joinRoom(ctx, from, roomId, bytesField(args, "invite")) {
+			panic("Player can not join the room")
 		}
+	case "answer":
+		var room = getRoom(ctx, resolveRoomId(ctx, args["room"].(string)))
+		fee = room.Config.AnswerFee
+		roomId = room.Id
+		checkToken(room, token)
+		checkPayment(amount, fee)
+
+		if !sendAnswer(ctx, from, roomId, bytesField(args, "commitment")) {
+			panic("Player can not send answer")
+		}
+	default:
+		panic("Unknown action")
 	}
-	room.Players = players
 
-	var result string
-	for i, answer := range wonAnswers {
-		result += fmt.Sprintf("place:%d, winner:%s, votes:%s\n", i, answer.Wallet, answer.Votes)
+	depositFee(ctx, roomId, token, fee)
+	var self = runtime.GetExecutingScriptHash()
+	if amount > fee && !contract.Call(token, "transfer", contract.All, self, from, amount-fee, nil).(bool) {
+		panic("Failed to return overpayment")
 	}
+}
 
-	sendMessageToPlayers("RoundWinners", result)
+// SESSION KEYS
 
-	sendRewardRoundWinners(ctx, &room, wonAnswers)
+func makeDelegateKey(wallet interop.Hash160, delegate interop.Hash160) []byte {
+	return append(append([]byte(delegatePrefix), wallet...), delegate...)
+}
 
-	room.Status = StatusGaming // Next game cycle available to AskQuestion
-	setRoom(ctx, &room)
-	return true
+func getDelegate(ctx storage.Context, key []byte) (Delegate, bool) {
+	var data = storage.Get(ctx, key)
+	if data == nil {
+		return Delegate{}, false
+	}
+
+	return std.Deserialize(data.([]byte)).(Delegate), true
 }
 
-func VoteToFinishGame(roomId string) bool {
-	var ctx = storage.GetContext()
-	var room = getRoom(ctx, roomId)
-	var wallet = getSender()
+func isDelegateValid(delegate Delegate, roomId string) bool {
+	return (delegate.RoomId == "" || delegate.RoomId == roomId) &&
+		(delegate.ExpiresAt == 0 || ledger.CurrentIndex() <= delegate.ExpiresAt)
+}
 
-	if room.Host.Equals(wallet) {
-		return false // Host cannot vote to finish game
+// Function to find who signed the action of the wallet in the room: the wallet itself or its delegate, which is valid
+// for the room and can spend the fee of the action. Returns key of the delegate, nil if the wallet signed itself
+func findSigner(ctx storage.Context, wallet interop.Hash160, roomId string, fee int) ([]byte, bool) {
+	if runtime.CheckWitness(wallet) {
+		return nil, true
 	}
 
-	var voted = 0
-	var isFound = false
-	for i, p := range room.Players {
-		if p.Wallet.Equals(wallet) {
-			if p.IsVotedToFinish {
-				return false // Player has already voted to finish the game
-			}
-			room.Players[i].IsVotedToFinish = true
-			isFound = true
-		}
-
-		if p.IsVotedToFinish {
-			voted++ // Count voted players to finish the game
+	var iter = storage.Find(ctx, append([]byte(delegatePrefix), wallet...), storage.KeysOnly)
+	for iterator.Next(iter) {
+		var key = iterator.Value(iter).([]byte)
+		var delegate, _ = getDelegate(ctx, key)
+		if isDelegateValid(delegate, roomId) && delegate.Spent+fee <= delegate.SpendLimit && runtime.CheckWitness(delegate.Account) {
+			return key, true
 		}
 	}
 
-	if !isFound {
-		return false // Player was not found in the room
-	}
+	return nil, false
+}
 
-	var result = fmt.Sprintf("voted to finish game:%d, need votes:%d", voted, len(room.Players))
-	sendMessageToPlayers("FinishVote", result)
+// Function to check that the action of the wallet is signed by the wallet or its delegate, see findSigner
+func requireSigner(ctx storage.Context, wallet interop.Hash160, roomId string, fee int) []byte {
+	var key, signed = findSigner(ctx, wallet, roomId, fee)
+	if !signed {
+		panic(errUnauthorized)
+	}
 
-	return automaticFinishGame(ctx, &room, voted)
+	return key
 }
 
-func automaticFinishGame(ctx storage.Context, room *Room, voted int) bool {
-	if voted != len(room.Players) {
-		return false // All players must have voted to finish the game
+// Function to count fee paid by delegate, called after the action succeeded
+func spendByDelegate(ctx storage.Context, key []byte, fee int) {
+	if key == nil || fee == 0 {
+		return
 	}
 
-	return finishGame(ctx, room)
+	var delegate, _ = getDelegate(ctx, key)
+	delegate.Spent += fee
+	storage.Put(ctx, key, std.Serialize(delegate))
 }
 
-func ManuallyFinishGame(roomId string) bool {
-	var ctx = storage.GetContext()
-	var room = getRoom(ctx, roomId)
+// AuthorizeDelegate allows wallet to give session key the right to play for it: confirm readiness, send, reveal and vote
+// answers and vote to finish the game. Delegate is valid in one room (roomId) or until the block (expiresAt), at least
+// one of them must be set. Delegate can spend up to spendLimit of the wallet's prepaid balance on answer fees.
+// Authorizing the same delegate again replaces its scope and resets its spending
+func AuthorizeDelegate(wallet interop.Hash160, delegate interop.Hash160, roomId string, expiresAt int, spendLimit int) bool {
+	authorize(wallet)
+	if len(delegate) != interop.Hash160Len || delegate.Equals(wallet) {
+		return false // Delegate must be another account
+	}
 
-	if !room.Host.Equals(getSender()) || room.Status != StatusGaming {
-		return false // Only host can finish game, room status must be gaming
+	if (roomId == "" && expiresAt == 0) || (expiresAt != 0 && expiresAt < ledger.CurrentIndex()) || spendLimit < 0 {
+		return false // Delegate must be limited by room or block, block must not be in the past, limit must not be negative
 	}
 
-	return finishGame(ctx, &room)
+	var ctx = storage.GetContext()
+	var value = Delegate{
+		Account:    delegate,
+		RoomId:     roomId,
+		ExpiresAt:  expiresAt,
+		SpendLimit: spendLimit,
+		Spent:      0,
+	}
+	storage.Put(ctx, makeDelegateKey(wallet, delegate), std.Serialize(value))
+	runtime.Notify("DelegateAuthorized", wallet, delegate, roomId, expiresAt, spendLimit)
+	return true
 }
 
-func chooseWonPlayers(room *Room, GameWinnersCount int) []Player {
-	var players = room.Players
-	var winners []Player
-	sort.Slice(players, func(a, b int) bool {
-		return players[a].RoundsWon > players[b].RoundsWon
-	})
-
-	if GameWinnersCount > len(room.Players) {
-		return room.Players
+// RevokeDelegate removes session key of the wallet, can be signed by the wallet or by the delegate itself
+func RevokeDelegate(wallet interop.Hash160, delegate interop.Hash160) bool {
+	var ctx = storage.GetContext()
+	var key = makeDelegateKey(wallet, delegate)
+	if _, found := getDelegate(ctx, key); !found {
+		return false // Delegate was not found
 	}
 
-	if GameWinnersCount == 1 {
-		return []Player{players[0]}
+	if !runtime.CheckWitness(wallet) && !runtime.CheckWitness(delegate) {
+		panic(errUnauthorized) // Only wallet or delegate can revoke delegate
 	}
 
-	var lastWinner = players[0].RoundsWon
-	winners = append(winners, players[0])
-	for i := 1; i < len(players) && len(winners) < GameWinnersCount; i++ {
-		var currentVote = players[i].RoundsWon
-		if lastWinner == currentVote {
-			GameWinnersCount++ // todo: Могут возникнуть проблемы, надо протестировать
-		}
-		lastWinner = currentVote
-		winners = append(winners, players[i])
+	storage.Delete(ctx, key)
+	runtime.Notify("DelegateRevoked", wallet, delegate)
+	return true
+}
+
+// GetDelegate returns scope and spending of the wallet's delegate, nil if delegate was not found
+func GetDelegate(wallet interop.Hash160, delegate interop.Hash160) map[string]any {
+	var value, found = getDelegate(storage.GetReadOnlyContext(), makeDelegateKey(wallet, delegate))
+	if !found {
+		return nil
 	}
 
-	return winners
+	return map[string]any{
+		"roomId":     value.RoomId,
+		"expiresAt":  value.ExpiresAt,
+		"spendLimit": value.SpendLimit,
+		"spent":      value.Spent,
+	}
 }
 
-func finishGame(ctx storage.Context, room *Room) bool {
-	var winners = chooseWonPlayers(room, room.GameWinnersCount)
+// MAIN METHODS TO PLAY IN GAME
+
+// Function to create room in the token with economic config, empty config means default fees and shares.
+// Caller takes room creation commission from the host
+func createRoom(ctx storage.Context, host interop.Hash160, token interop.Hash160, RoundWinnersCount int, GameWinnersCount int, config map[string]any) string {
+	if RoundWinnersCount < 1 || GameWinnersCount < 1 {
+		panic("Count of round and game winners must be at least 1")
+	}
 
-	var result = fmt.Sprintf("finish the game! count winners:%d\n", len(winners))
-	for i, player := range winners {
-		result += fmt.Sprintf("place:%d, player:%s, score:%d\n", i, player.Wallet, player.RoundsWon)
+	var id, shortId = makeRoomIds(ctx)
+	var unit = tokenUnit(token)
+	var roomConfig = parseRoomConfig(config, unit)
+	// Fee is fixed for the room, so later changes of platform fee don't affect players who already joined
+	var platformFee = contract.Call(getMoneyContractHash(ctx), "getPlatformFee", contract.ReadStates).(int)
+
+	if !contract.Call(getMoneyContractHash(ctx), "openRoom", contract.All, id, token).(bool) {
+		panic("Token is not allowed for rooms")
 	}
-	sendMessageToPlayers("FinishGame", result)
 
-	sendRewardGameWinners(ctx, room, winners)
-	// Host reward remains on the money_contract.go wallet, from which he can withdraw money to his personal wallet.
+	var room = &Room{
+		Id:                id,
+		ShortId:           shortId,
+		Host:              host,
+		Token:             token,
+		Commission:        createRoomCommission * unit,
+		GamePrizePool:     createRoomCommission * unit,
+		RoundPrizePool:    0,
+		RoundWinnersCount: RoundWinnersCount,
+		GameWinnersCount:  GameWinnersCount,
+		RefundPolicy:      RefundFull,
+		CancellationFee:   0,
+		TiePolicy:         rules.TieAll,
+		Config:            roomConfig,
+		PlatformFee:       platformFee,
+		MinPlayers:        defaultMinPlayers,
+		MaxPlayers:        defaultMaxPlayers,
+		IsPrivate:         false,
+		InviteKey:         nil,
+		Timeouts: PhaseTimeouts{
+			Readiness:    defaultReadinessTimeout,
+			Question:     defaultQuestionTimeout,
+			Answering:    defaultAnsweringTimeout,
+			Reveal:       defaultRevealTimeout,
+			Voting:       defaultVotingTimeout,
+			AbandonGrace: defaultAbandonGrace,
+		},
+		PlayersCount: 0,
+		ReadyCount:   0,
+		FinishVotes:  0,
+		RoundsCount:  0,
+	}
+	setPhase(ctx, room, StatusWaiting)
+	storage.Put(ctx, makeHostIndexKey(host, id), id)
+	storage.Put(ctx, shortIdPrefix+shortId, id)
 
-	room.Status = StatusFinished
 	setRoom(ctx, room)
-	return true
+	runtime.Notify("RoomCreated", id, host)
+	return id
+}
+
+// CreateRoom creates room with economic config in JSON, token of the room is set by "token" field of the config.
+// Commission is charged from the host's prepaid balance in money contract. Room can also be created with
+// token transfer to the room contract, see OnNEP17Payment
+func CreateRoom(host interop.Hash160, RoundWinnersCount int, GameWinnersCount int, config []byte) string {
+	var ctx = storage.GetContext()
+	authorize(host)
+
+	var data = map[string]any{}
+	if len(config) != 0 {
+		data = std.JSONDeserialize(config).(map[string]any)
+	}
+
+	var id = createRoom(ctx, host, tokenField(data), RoundWinnersCount, GameWinnersCount, data)
+	chargeFee(ctx, id, host, getRoom(ctx, id).Commission)
+	return id
+}
+
+// SetPhaseTimeouts allows host to change count of blocks for each phase and abandon grace period
+// before players join the room
+func SetPhaseTimeouts(host interop.Hash160, roomId string, readiness int, question int, answering int, reveal int, voting int, abandonGrace int) bool {
+	var ctx = storage.GetContext()
+	var room = getRoom(ctx, roomId)
+
+	authorizeHost(room, host)
+	if room.Status != StatusWaiting || room.PlayersCount != 0 {
+		return false // Room status must be waiting and players must not have joined yet
+	}
+
+	for _, timeout := range []int{readiness, question, answering, reveal, voting, abandonGrace} {
+		if timeout <= 0 || timeout > maxPhaseTimeout {
+			return false // Every phase and grace period must be positive and not greater than maxPhaseTimeout
+		}
+	}
+
+	room.Timeouts = PhaseTimeouts{
+		Readiness:    readiness,
+		Question:     question,
+		Answering:    answering,
+		Reveal:       reveal,
+		Voting:       voting,
+		AbandonGrace: abandonGrace,
+	}
+	setPhase(ctx, room, StatusWaiting)
+	setRoom(ctx, room)
+	return true
+}
+
+// Function to add wallet to the room players, caller takes entry fee from the player. Invite is required
+// only for private room when wallet is not allowlisted, it is host's signature of roomId + wallet
+func joinRoom(ctx storage.Context, wallet interop.Hash160, roomId string, invite []byte) bool {
+	var room = getRoom(ctx, roomId)
+
+	if room.Host.Equals(wallet) || room.Status != StatusWaiting {
+		return false // Host can not be player, player cannot join started room
+	}
+
+	if isPlayerBanned(ctx, roomId, wallet) || room.PlayersCount >= room.MaxPlayers {
+		return false // Player was banned by host, room is full
+	}
+
+	if room.IsPrivate && !isPlayerAllowed(ctx, roomId, wallet) && !isInviteValid(room, wallet, invite) {
+		return false // Room is private, player must be allowlisted or have valid invite
+	}
+
+	if _, found := getPlayer(ctx, roomId, wallet); found {
+		return false // Player already joined room
+	}
+
+	room.GamePrizePool += room.Config.EntryFee
+
+	var player = Player{
+		Wallet:          wallet,
+		Paid:            room.Config.EntryFee,
+		RoundsWon:       0,
+		JoinedAt:        ledger.CurrentIndex(),
+		IsReady:         false,
+		IsVotedToFinish: false,
+		isActive:        true,
+	}
+
+	setPlayer(ctx, room.Id, player)
+	room.PlayersCount += 1
+	storage.Put(ctx, makePlayerIndexKey(wallet, room.Id), room.Id)
+	runtime.Notify("PlayerJoined", room.Id, wallet)
+	setRoom(ctx, room)
+	return true
+}
+
+// JoinRoom adds sender to the room players, entry fee is charged from the player's prepaid balance in money contract.
+// Player can also join with token transfer to the room contract, see OnNEP17Payment
+func JoinRoom(wallet interop.Hash160, roomId string, invite []byte) bool {
+	var ctx = storage.GetContext()
+	var room = getRoom(ctx, roomId)
+	authorize(wallet)
+
+	if !joinRoom(ctx, wallet, room.Id, invite) {
+		return false
+	}
+
+	chargeFee(ctx, room.Id, wallet, room.Config.EntryFee)
+	return true
+}
+
+// SetRefundPolicy allows host to choose refund policy for leaving players before players join the room
+func SetRefundPolicy(host interop.Hash160, roomId string, policy string, fee int) bool {
+	var ctx = storage.GetContext()
+	var room = getRoom(ctx, roomId)
+
+	authorizeHost(room, host)
+	if room.Status != StatusWaiting || room.PlayersCount != 0 {
+		return false // Room status must be waiting and players must not have joined yet
+	}
+
+	if policy == RefundFull {
+		fee = 0
+	} else if policy != RefundMinusFee || fee < 0 || fee > room.Config.EntryFee {
+		return false // Unknown policy, fee must not be greater than join commission
+	}
+
+	room.RefundPolicy = policy
+	room.CancellationFee = fee
+	setRoom(ctx, room)
+	return true
+}
+
+// SetTiePolicy sets who wins when answers or players share the last winning place:
+// "all", "split", "earliest" or "random"
+func SetTiePolicy(host interop.Hash160, roomId string, policy string) bool {
+	var ctx = storage.GetContext()
+	var room = getRoom(ctx, roomId)
+
+	authorizeHost(room, host)
+	if room.Status != StatusWaiting || room.PlayersCount != 0 {
+		return false // Room status must be waiting and players must not have joined yet
+	}
+
+	if !rules.IsTiePolicy(policy) {
+		return false // Unknown tie policy
+	}
+
+	room.TiePolicy = policy
+	setRoom(ctx, room)
+	return true
+}
+
+// Function to get seed for random tie policy, other policies don't need randomness
+func tieSeed(room *Room) int {
+	if room.TiePolicy != rules.TieRandom {
+		return 0
+	}
+
+	var seed = runtime.GetRandom()
+	if seed < 0 {
+		seed = -seed
+	}
+	return seed
+}
+
+// Function to convert tie of the last winning place to event format [score, candidates, seed]. Candidates are wallets
+// of all entries with the score of the last winning place, including the ones which lost TieEarliest or TieRandom draw,
+// seed is the runtime.GetRandom value used by TieRandom, 0 for other policies
+func tieToEvent(score int, group []int, wallets []interop.Hash160, seed int) []any {
+	var candidates = []any{}
+	for _, idx := range group {
+		candidates = append(candidates, wallets[idx])
+	}
+
+	return []any{score, candidates, seed}
+}
+
+// Function to convert winners to event format [place, wallet, score, tied, share]
+func winnersToEvent(winners []rules.Winner, wallets []interop.Hash160) []any {
+	var result = []any{}
+	for i, winner := range winners {
+		result = append(result, []any{winner.Place, wallets[i], winner.Score, winner.Tied, winner.Share})
+	}
+
+	return result
+}
+
+// Function to calculate refund of paid commission according to room's refund policy
+func refundAmount(room *Room, paid int) int {
+	return rules.RefundAmount(room.RefundPolicy, room.CancellationFee, paid)
+}
+
+// Function to remove player from the lobby and update room counters
+func removePlayer(ctx storage.Context, room *Room, player Player) {
+	deletePlayer(ctx, room.Id, player.Wallet)
+	room.PlayersCount -= 1
+	if player.IsReady {
+		room.ReadyCount -= 1
+	}
+	if player.IsVotedToFinish {
+		room.FinishVotes -= 1
+	}
+}
+
+// Function to credit refund from room's game pool to claimable balance, panics if it failed so player stays in the room
+func sendRefund(ctx storage.Context, room *Room, wallet interop.Hash160, refund int) {
+	if refund == 0 {
+		return
+	}
+
+	var result = contract.Call(getMoneyContractHash(ctx), "rewardPlayer", contract.All, room.Id, wallet, refund).(bool)
+	if !result {
+		panic("Failed to refund commission")
+	}
+	room.GamePrizePool -= refund
+}
+
+// LeaveRoom allows player to leave room before the game starts, join commission is refunded by room's refund policy
+func LeaveRoom(wallet interop.Hash160, roomId string) bool {
+	var ctx = storage.GetContext()
+	var room = getRoom(ctx, roomId)
+	authorize(wallet)
+
+	if room.Status != StatusWaiting {
+		return false // Player can leave room only before the game starts
+	}
+
+	var player, found = getPlayer(ctx, roomId, wallet)
+	if !found {
+		return false // Player not found in the room
+	}
+
+	var refund = refundAmount(room, player.Paid)
+	sendRefund(ctx, room, wallet, refund)
+	removePlayer(ctx, room, player)
+
+	runtime.Notify("PlayerLeft", room.Id, wallet, refund)
+	setRoom(ctx, room)
+	return true
+}
+
+// CancelRoom allows host to cancel room before the game starts. Players get back the whole join commission,
+// host gets back create commission by room's refund policy
+func CancelRoom(host interop.Hash160, roomId string) bool {
+	var ctx = storage.GetContext()
+	var room = getRoom(ctx, roomId)
+
+	authorizeHost(room, host)
+	if room.Status != StatusWaiting {
+		return false // Room status must be waiting
+	}
+
+	for _, player := range getPlayers(ctx, roomId) {
+		sendRefund(ctx, room, player.Wallet, player.Paid)
+		removePlayer(ctx, room, player)
+		runtime.Notify("PlayerLeft", room.Id, player.Wallet, player.Paid)
+	}
+
+	var hostRefund = refundAmount(room, room.Commission)
+	sendRefund(ctx, room, room.Host, hostRefund)
+	runtime.Notify("RoomCancelled", room.Id, room.Host, hostRefund)
+
+	// Cancellation fee of the host is settled as platform earnings
+	settleEarnings(ctx, room, 0, room.GamePrizePool)
+	room.GamePrizePool = 0
+	setPhase(ctx, room, StatusCancelled)
+	setRoom(ctx, room)
+	return true
+}
+
+// SetPlayerLimits allows host to set min and max count of players in the room before the game starts
+func SetPlayerLimits(host interop.Hash160, roomId string, minPlayers int, maxPlayers int) bool {
+	var ctx = storage.GetContext()
+	var room = getRoom(ctx, roomId)
+
+	authorizeHost(room, host)
+	if room.Status != StatusWaiting {
+		return false // Room status must be waiting
+	}
+
+	if minPlayers < 1 || minPlayers > maxPlayers || maxPlayers > maxRoomPlayers || maxPlayers < room.PlayersCount {
+		return false // Limits must be 1 <= min <= max <= maxRoomPlayers, joined players must fit into max
+	}
+
+	room.MinPlayers = minPlayers
+	room.MaxPlayers = maxPlayers
+	setRoom(ctx, room)
+	return true
+}
+
+// SetPrivate allows host to make room private or public before the game starts
+func SetPrivate(host interop.Hash160, roomId string, isPrivate bool) bool {
+	var ctx = storage.GetContext()
+	var room = getRoom(ctx, roomId)
+
+	authorizeHost(room, host)
+	if room.Status != StatusWaiting {
+		return false // Room status must be waiting
+	}
+
+	room.IsPrivate = isPrivate
+	setRoom(ctx, room)
+	return true
+}
+
+// SetInviteKey sets public key which signs invite codes for the private room. The key is authorized by the host's witness,
+// so it does not have to be the key of the host's wallet and contract hosts can use invites too
+func SetInviteKey(host interop.Hash160, roomId string, pubKey interop.PublicKey) bool {
+	var ctx = storage.GetContext()
+	var room = getRoom(ctx, roomId)
+
+	authorizeHost(room, host)
+	if room.Status != StatusWaiting {
+		return false // Room status must be waiting
+	}
+
+	if len(pubKey) != interop.PublicKeyCompressedLen {
+		return false // Key must be compressed public key
+	}
+
+	room.InviteKey = pubKey
+	setRoom(ctx, room)
+	return true
+}
+
+// AllowPlayer adds wallet to the allowlist of the private room
+func AllowPlayer(host interop.Hash160, roomId string, wallet interop.Hash160) bool {
+	var ctx = storage.GetContext()
+	var room = getRoom(ctx, roomId)
+
+	authorizeHost(room, host)
+	if room.Status != StatusWaiting || len(wallet) != interop.Hash160Len {
+		return false // Room status must be waiting, wallet must be valid
+	}
+
+	if isPlayerAllowed(ctx, roomId, wallet) {
+		return false // Player is already allowed
+	}
+
+	storage.Put(ctx, makeAllowedKey(roomId, wallet), true)
+	return true
+}
+
+// DisallowPlayer removes wallet from the allowlist of the private room, joined player stays in the room
+func DisallowPlayer(host interop.Hash160, roomId string, wallet interop.Hash160) bool {
+	var ctx = storage.GetContext()
+	var room = getRoom(ctx, roomId)
+
+	authorizeHost(room, host)
+	if !isPlayerAllowed(ctx, roomId, wallet) {
+		return false // Player must be allowed
+	}
+
+	storage.Delete(ctx, makeAllowedKey(roomId, wallet))
+	return true
+}
+
+func isPlayerAllowed(ctx storage.Context, roomId string, wallet interop.Hash160) bool {
+	return storage.Get(ctx, makeAllowedKey(roomId, wallet)) != nil
+}
+
+// Function to verify invite code, it is signature of roomId + wallet made with host's invite key
+func isInviteValid(room *Room, wallet interop.Hash160, invite []byte) bool {
+	if len(room.InviteKey) == 0 || len(invite) != interop.SignatureLen {
+		return false
+	}
+
+	var message = []byte{}
+	message = append(message, []byte(room.Id)...)
+	message = append(message, wallet...)
+	return crypto.VerifyWithECDsa(message, room.InviteKey, invite, crypto.Secp256r1Sha256)
+}
+
+func isPlayerBanned(ctx storage.Context, roomId string, wallet interop.Hash160) bool {
+	return storage.Get(ctx, makeBannedKey(roomId, wallet)) != nil
+}
+
+// Function to remove player from the lobby with full refund of the join commission
+func kickPlayer(ctx storage.Context, room *Room, wallet interop.Hash160) bool {
+	var player, found = getPlayer(ctx, room.Id, wallet)
+	if !found {
+		return false // Player not found in the room
+	}
+
+	sendRefund(ctx, room, wallet, player.Paid)
+	removePlayer(ctx, room, player)
+
+	runtime.Notify("PlayerKicked", room.Id, wallet, player.Paid)
+	return true
+}
+
+// KickPlayer allows host to remove player from the room before the game starts, join commission is refunded
+func KickPlayer(host interop.Hash160, roomId string, wallet interop.Hash160) bool {
+	var ctx = storage.GetContext()
+	var room = getRoom(ctx, roomId)
+
+	authorizeHost(room, host)
+	if room.Status != StatusWaiting {
+		return false // Room status must be waiting
+	}
+
+	if !kickPlayer(ctx, room, wallet) {
+		return false
+	}
+
+	setRoom(ctx, room)
+	return true
+}
+
+// BanPlayer adds wallet to room's ban list, if player has already joined the room he is kicked
+func BanPlayer(host interop.Hash160, roomId string, wallet interop.Hash160) bool {
+	var ctx = storage.GetContext()
+	var room = getRoom(ctx, roomId)
+
+	authorizeHost(room, host)
+	if room.Status != StatusWaiting || len(wallet) != interop.Hash160Len {
+		return false // Room status must be waiting, wallet must be valid
+	}
+
+	if isPlayerBanned(ctx, roomId, wallet) {
+		return false // Player is already banned
+	}
+
+	if kickPlayer(ctx, room, wallet) {
+		setRoom(ctx, room)
+	}
+	storage.Put(ctx, makeBannedKey(roomId, wallet), true)
+	return true
+}
+
+// UnbanPlayer removes wallet from room's ban list
+func UnbanPlayer(host interop.Hash160, roomId string, wallet interop.Hash160) bool {
+	var ctx = storage.GetContext()
+	var room = getRoom(ctx, roomId)
+
+	authorizeHost(room, host)
+	if !isPlayerBanned(ctx, roomId, wallet) {
+		return false // Player must be banned
+	}
+
+	storage.Delete(ctx, makeBannedKey(roomId, wallet))
+	return true
+}
+
+func ConfirmReadiness(wallet interop.Hash160, roomId string) bool {
+	var ctx = storage.GetContext()
+	var room = getRoom(ctx, roomId)
+
+	requireSigner(ctx, wallet, room.Id, 0)
+
+	var player, found = getPlayer(ctx, roomId, wallet)
+	if !found {
+		return false // Player not found in the room
+	}
+
+	if player.IsReady || !player.isActive {
+		return false // Player is already ready, player must be active
+	}
+
+	player.IsReady = true
+	setPlayer(ctx, roomId, player)
+	room.ReadyCount += 1
+
+	runtime.Notify("PlayerReady", room.Id, wallet)
+	setRoom(ctx, room)
+	return true
+}
+
+func StartGame(host interop.Hash160, roomId string) bool {
+	var ctx = storage.GetContext()
+	var room = getRoom(ctx, roomId)
+
+	authorizeHost(room, host)
+
+	return startGame(ctx, room)
+}
+
+func startGame(ctx storage.Context, room *Room) bool {
+	if room.Status != StatusWaiting || !rules.CanStart(room.PlayersCount, room.ReadyCount, room.MinPlayers, room.RoundWinnersCount) {
+		return false // Room status must be waiting, players count must be > count winners and >= min players, all players must be ready
+	}
+
+	setPhase(ctx, room, StatusGaming)
+	setRoom(ctx, room)
+	return true
+}
+
+func checkingForUniqueness(ctx storage.Context, roomId string, tokenId []byte) bool {
+	return storage.Get(ctx, makeTokenKey(roomId, tokenId)) == nil
+}
+
+func AskQuestion(host interop.Hash160, roomId string, tokenId []byte) bool {
+	var ctx = storage.GetContext()
+	var room = getRoom(ctx, roomId)
+
+	authorizeHost(room, host)
+	if room.Status != StatusGaming {
+		return false // Room status must be gaming
+	}
+
+	// Get token properties from nft contract
+	var tokenProperties = contract.Call(getNftContractHash(ctx), "properties", contract.All, tokenId).(map[string]string)
+	if tokenProperties == nil || tokenProperties["owner"] != string(host) || !checkingForUniqueness(ctx, roomId, tokenId) {
+		return false // NFT was not found, host is not the owner of question, round must contain unique questions
+	}
+
+	var question = tokenProperties["question"]
+	var round = Round{
+		TokenId:      tokenId,
+		Question:     question,
+		AnswersCount: 0,
+	}
+	setRound(ctx, roomId, room.RoundsCount, round)
+	storage.Put(ctx, makeTokenKey(roomId, tokenId), room.RoundsCount)
+	room.RoundsCount += 1
+	setPhase(ctx, room, StatusAnswering)
+
+	runtime.Notify("RoundQuestion", room.Id, room.RoundsCount-1, tokenId, question)
+
+	setRoom(ctx, room)
+	return true
+}
+
+// Function to build answer commitment, the same hash must be computed off-chain by the player:
+// sha256(wallet + salt + content). Wallet is included so that a commitment can not be copied by another player.
+// Salt must be exactly saltLen bytes, otherwise the same hash could be revealed as a different text
+func makeCommitment(wallet interop.Hash160, salt []byte, content string) []byte {
+	var data = []byte{}
+	data = append(data, wallet...)
+	data = append(data, salt...)
+	data = append(data, []byte(content)...)
+	return crypto.Sha256(data)
+}
+
+// Function to accept only the commitment of the answer, the answer itself is revealed after EndQuestion.
+// Caller takes answer fee from the player
+func sendAnswer(ctx storage.Context, wallet interop.Hash160, roomId string, commitment []byte) bool {
+	var room = getRoom(ctx, roomId)
+
+	var player, found = getPlayer(ctx, roomId, wallet)
+	if !found || !player.isActive || room.Status != StatusAnswering {
+		return false // Only player can send content, player must be active, room status must be answering
+	}
+
+	if len(commitment) != interop.Hash256Len {
+		return false // Commitment must be sha256 hash
+	}
+
+	var roundIdx = room.RoundsCount - 1
+	if getAnswerIdx(ctx, roomId, roundIdx, wallet) >= 0 {
+		return false // Player cannot send answer twice
+	}
+
+	room.RoundPrizePool += room.Config.AnswerFee
+	player.Paid += room.Config.AnswerFee
+	setPlayer(ctx, roomId, player)
+
+	var round = getRound(ctx, roomId, roundIdx)
+	var answer = Answer{
+		Index:      round.AnswersCount,
+		Wallet:     wallet,
+		Commitment: commitment,
+		Content:    "",
+		IsRevealed: false,
+		Votes:      0,
+	}
+	setAnswer(ctx, roomId, roundIdx, answer)
+	storage.Put(ctx, makeAnswerIdxKey(roomId, roundIdx, wallet), answer.Index)
+
+	round.AnswersCount += 1
+	setRound(ctx, roomId, roundIdx, round)
+
+	runtime.Notify("AnswerSent", room.Id, roundIdx, wallet)
+	setRoom(ctx, room)
+	return true
+}
+
+// SendAnswer accepts the commitment of the answer, answer fee is charged from the player's prepaid balance in money contract.
+// Answer can be sent by player's delegate, see AuthorizeDelegate, or with token transfer to the room contract, see OnNEP17Payment
+func SendAnswer(wallet interop.Hash160, roomId string, commitment []byte) bool {
+	var ctx = storage.GetContext()
+	var room = getRoom(ctx, roomId)
+
+	var delegateKey = requireSigner(ctx, wallet, room.Id, room.Config.AnswerFee)
+
+	if !sendAnswer(ctx, wallet, room.Id, commitment) {
+		return false
+	}
+
+	chargeFee(ctx, room.Id, wallet, room.Config.AnswerFee)
+	spendByDelegate(ctx, delegateKey, room.Config.AnswerFee)
+	return true
+}
+
+// Function to deactivate players who did not reveal answer in the previous and the current rounds
+func deactivatingPlayers(ctx storage.Context, room *Room) {
+	var previous, current = room.RoundsCount - 2, room.RoundsCount - 1
+	for _, player := range getPlayers(ctx, room.Id) {
+		var isActive = rules.IsPlayerActive(room.RoundsCount,
+			hasRevealedAnswer(ctx, room.Id, previous, player.Wallet), hasRevealedAnswer(ctx, room.Id, current, player.Wallet))
+
+		if player.isActive != isActive {
+			player.isActive = isActive
+			setPlayer(ctx, room.Id, player)
+		}
+	}
+}
+
+func EndQuestion(host interop.Hash160, roomId string) bool {
+	var ctx = storage.GetContext()
+	var room = getRoom(ctx, roomId)
+
+	authorizeHost(room, host)
+
+	return endQuestion(ctx, room)
+}
+
+func endQuestion(ctx storage.Context, room *Room) bool {
+	if room.Status != StatusAnswering {
+		return false // Room status must be answering
+	}
+
+	setPhase(ctx, room, StatusRevealing) // Answering is closed, players can reveal their answers
+
+	var round = getRound(ctx, room.Id, room.RoundsCount-1)
+	runtime.Notify("RevealAnswers", room.Id, room.RoundsCount-1, round.AnswersCount)
+
+	setRoom(ctx, room)
+	return true
+}
+
+// RevealAnswer opens the answer behind the commitment sent in SendAnswer
+func RevealAnswer(wallet interop.Hash160, roomId string, text string, salt []byte) bool {
+	var ctx = storage.GetContext()
+	var room = getRoom(ctx, roomId)
+
+	requireSigner(ctx, wallet, room.Id, 0)
+
+	if room.Status != StatusRevealing {
+		return false // Room status must be revealing
+	}
+
+	if len(salt) != saltLen {
+		return false // Salt must be 32 bytes
+	}
+
+	var roundIdx = room.RoundsCount - 1
+	var answerIdx = getAnswerIdx(ctx, roomId, roundIdx, wallet)
+	if answerIdx < 0 {
+		return false // Player did not send answer in this round
+	}
+
+	var answer = getAnswer(ctx, roomId, roundIdx, answerIdx)
+	if answer.IsRevealed || string(answer.Commitment) != string(makeCommitment(wallet, salt, text)) {
+		return false // Answer is already revealed, text and salt must match commitment
+	}
+
+	answer.Content = text
+	answer.IsRevealed = true
+	setAnswer(ctx, roomId, roundIdx, answer)
+
+	runtime.Notify("AnswerRevealed", room.Id, roundIdx, wallet)
+	return true
+}
+
+// Function to leave only revealed answers of the round, unrevealed commitments are counted as non-answers
+func filterRevealedAnswers(answers []Answer) []Answer {
+	var revealed = []Answer{}
+	for _, answer := range answers {
+		if answer.IsRevealed {
+			revealed = append(revealed, answer)
+		}
+	}
+
+	return revealed
+}
+
+// EndReveal closes reveal window, only revealed answers get into voting
+func EndReveal(host interop.Hash160, roomId string) bool {
+	var ctx = storage.GetContext()
+	var room = getRoom(ctx, roomId)
+
+	authorizeHost(room, host)
+
+	return endReveal(ctx, room)
+}
+
+func endReveal(ctx storage.Context, room *Room) bool {
+	if room.Status != StatusRevealing {
+		return false // Room status must be revealing
+	}
+
+	setPhase(ctx, room, StatusVoting)
+
+	var roundIdx = room.RoundsCount - 1
+	var round = getRound(ctx, room.Id, roundIdx)
+	var revealed = filterRevealedAnswers(getAnswers(ctx, room.Id, roundIdx, round.AnswersCount))
+
+	// Every answer is [index, wallet, content], index is used in VoteAnswer
+	var answers = []any{}
+	for _, answer := range revealed {
+		answers = append(answers, []any{answer.Index, answer.Wallet, answer.Content})
+	}
+	runtime.Notify("RoundAnswers", room.Id, roundIdx, answers)
+
+	if room.RoundsCount > 1 {
+		deactivatingPlayers(ctx, room)
+	}
+
+	setRoom(ctx, room)
+	return true
+}
+
+func VoteAnswer(wallet interop.Hash160, roomId string, answerIdx int) bool {
+	var ctx = storage.GetContext()
+	var room = getRoom(ctx, roomId)
+
+	requireSigner(ctx, wallet, room.Id, 0)
+
+	if room.Host.Equals(wallet) || isPlayerDeactivate(ctx, roomId, wallet) || room.Status != StatusVoting {
+		return false // Only player can choose answer, player must be active, room status must be voting
+	}
+
+	var roundIdx = room.RoundsCount - 1
+	var round = getRound(ctx, roomId, roundIdx)
+	if !(0 <= answerIdx && answerIdx < round.AnswersCount) {
+		return false // answerIdx is incorrect
+	}
+
+	var answer = getAnswer(ctx, roomId, roundIdx, answerIdx)
+	if !answer.IsRevealed || answer.Wallet.Equals(wallet) {
+		return false // Unrevealed answer is not in voting and player cannot vote for himself
+	}
+
+	var voteKey = makeVoteKey(roomId, roundIdx, wallet)
+	var voted = []int{}
+	if data := storage.Get(ctx, voteKey); data != nil {
+		voted = std.Deserialize(data.([]byte)).([]int)
+	}
+	for _, idx := range voted {
+		if idx == answerIdx {
+			return false // Player cannot vote twice for one answer
+		}
+	}
+
+	storage.Put(ctx, voteKey, std.Serialize(append(voted, answerIdx)))
+	answer.Votes += 1
+	setAnswer(ctx, roomId, roundIdx, answer)
+
+	runtime.Notify("AnswerVoted", room.Id, roundIdx, answerIdx, wallet)
+	return true
+}
+
+// Function to rank answers by votes, answers which share the last winning place are resolved by room's tie policy,
+// answer sent earlier has lower index. Tie is returned in event format to report the draw
+func chooseWonAnswers(room *Room, answers []Answer) ([]rules.Winner, []any) {
+	var votes = []int{}
+	var order = []int{}
+	var wallets = []interop.Hash160{}
+	for _, answer := range answers {
+		votes = append(votes, answer.Votes)
+		order = append(order, answer.Index)
+		wallets = append(wallets, answer.Wallet)
+	}
+
+	var seed = tieSeed(room)
+	var score, group = rules.TieGroup(votes, order, room.RoundWinnersCount)
+	return rules.Rank(votes, order, room.RoundWinnersCount, room.TiePolicy, seed), tieToEvent(score, group, wallets, seed)
+}
+
+func GetRoundWinner(host interop.Hash160, roomId string) bool {
+	var ctx = storage.GetContext()
+	var room = getRoom(ctx, roomId)
+
+	authorizeHost(room, host)
+
+	return getRoundWinner(ctx, room)
+}
+
+func getRoundWinner(ctx storage.Context, room *Room) bool {
+	if room.Status != StatusVoting {
+		return false // Room status must be voting
+	}
+
+	var roundIdx = room.RoundsCount - 1
+	var round = getRound(ctx, room.Id, roundIdx)
+	var answers = filterRevealedAnswers(getAnswers(ctx, room.Id, roundIdx, round.AnswersCount))
+	if len(answers) == 0 {
+		// Zero winners, because no answer was revealed. Round prize pool moves to the next round
+		runtime.Notify("RoundWinners", room.Id, roundIdx, room.TiePolicy, []any{}, tieToEvent(0, []int{}, nil, 0))
+		setPhase(ctx, room, StatusGaming)
+		setRoom(ctx, room)
+		return true
+	}
+
+	var winners, tie = chooseWonAnswers(room, answers)
+	var wallets = []interop.Hash160{}
+	for _, winner := range winners {
+		var wallet = answers[winner.Index].Wallet
+		wallets = append(wallets, wallet)
+
+		var player, found = getPlayer(ctx, room.Id, wallet)
+		if found {
+			player.RoundsWon += 1
+			setPlayer(ctx, room.Id, player)
+		}
+	}
+
+	// Every winner is [place, wallet, votes, tied, share], tie is [votes, candidates, seed]
+	runtime.Notify("RoundWinners", room.Id, roundIdx, room.TiePolicy, winnersToEvent(winners, wallets), tie)
+
+	sendRewardRoundWinners(ctx, room, answers, winners)
+
+	setPhase(ctx, room, StatusGaming) // Next game cycle available to AskQuestion
+	setRoom(ctx, room)
+	return true
+}
+
+func VoteToFinishGame(wallet interop.Hash160, roomId string) bool {
+	var ctx = storage.GetContext()
+	var room = getRoom(ctx, roomId)
+
+	requireSigner(ctx, wallet, room.Id, 0)
+
+	if room.Host.Equals(wallet) || isRoomClosed(room) {
+		return false // Host cannot vote to finish game, room must not be finished
+	}
+
+	var player, found = getPlayer(ctx, roomId, wallet)
+	if !found {
+		return false // Player was not found in the room
+	}
+
+	if player.IsVotedToFinish {
+		return false // Player has already voted to finish the game
+	}
+
+	player.IsVotedToFinish = true
+	setPlayer(ctx, roomId, player)
+	room.FinishVotes++ // Count voted players to finish the game
+
+	runtime.Notify("FinishVote", room.Id, wallet, room.FinishVotes, room.PlayersCount)
+	setRoom(ctx, room)
+
+	return automaticFinishGame(ctx, room, room.FinishVotes)
+}
+
+func automaticFinishGame(ctx storage.Context, room *Room, voted int) bool {
+	if voted != room.PlayersCount {
+		return false // All players must have voted to finish the game
+	}
+
+	return finishGame(ctx, room)
+}
+
+func ManuallyFinishGame(host interop.Hash160, roomId string) bool {
+	var ctx = storage.GetContext()
+	var room = getRoom(ctx, roomId)
+
+	authorizeHost(room, host)
+	if room.Status != StatusGaming {
+		return false // Room status must be gaming
+	}
+
+	return finishGame(ctx, room)
+}
+
+// Function to rank players by won rounds, players who share the last winning place are resolved by room's tie policy,
+// player who joined earlier has lower block height. Tie is returned in event format to report the draw
+func chooseWonPlayers(room *Room, players []Player) ([]rules.Winner, []any) {
+	var roundsWon = []int{}
+	var order = []int{}
+	var wallets = []interop.Hash160{}
+	for _, player := range players {
+		roundsWon = append(roundsWon, player.RoundsWon)
+		order = append(order, player.JoinedAt)
+		wallets = append(wallets, player.Wallet)
+	}
+
+	var seed = tieSeed(room)
+	var score, group = rules.TieGroup(roundsWon, order, room.GameWinnersCount)
+	return rules.Rank(roundsWon, order, room.GameWinnersCount, room.TiePolicy, seed), tieToEvent(score, group, wallets, seed)
+}
+
+func finishGame(ctx storage.Context, room *Room) bool {
+	var players = getPlayers(ctx, room.Id)
+	var winners, tie = chooseWonPlayers(room, players)
+
+	var wallets = []interop.Hash160{}
+	for _, winner := range winners {
+		wallets = append(wallets, players[winner.Index].Wallet)
+	}
+
+	// Every winner is [place, wallet, score, tied, share], tie is [score, candidates, seed]
+	runtime.Notify("FinishGame", room.Id, room.TiePolicy, winnersToEvent(winners, wallets), tie)
+
+	if countRoundsWon(players) == 0 {
+		// Nothing was played, e.g. host started the game and did not ask questions, so players get their commissions back.
+		// Host finished the game properly, so create commission of the host is settled as host earnings
+		refundPlayers(ctx, room, rules.RemainderHost)
+	} else {
+		sendRewardGameWinners(ctx, room, players, winners)
+
+		// Pools left without winners are settled as host earnings
+		settleEarnings(ctx, room, room.GamePrizePool+room.RoundPrizePool, 0)
+		room.GamePrizePool = 0
+		room.RoundPrizePool = 0
+		// Host reward is settled on the money_contract.go wallet, from which he can withdraw money to his personal wallet.
+	}
+
+	setPhase(ctx, room, StatusFinished)
+	setRoom(ctx, room)
+	return true
+}
+
+// AdvancePhase can be called by anyone after the deadline of the current phase has passed.
+// It runs the same transition the host would have triggered:
+// waiting -> StartGame, gaming -> ManuallyFinishGame, answering -> EndQuestion,
+// revealing -> EndReveal, voting -> GetRoundWinner
+func AdvancePhase(roomId string) bool {
+	var ctx = storage.GetContext()
+	var room = getRoom(ctx, roomId)
+
+	if isRoomClosed(room) || ledger.CurrentIndex() <= room.PhaseDeadline {
+		return false // Room must not be finished, deadline of the current phase must pass
+	}
+
+	switch room.Status {
+	case StatusWaiting:
+		return startGame(ctx, room)
+	case StatusGaming:
+		return finishGame(ctx, room) // Host has not asked the next question in time
+	case StatusAnswering:
+		return endQuestion(ctx, room)
+	case StatusRevealing:
+		return endReveal(ctx, room)
+	case StatusVoting:
+		return getRoundWinner(ctx, room)
+	}
+
+	return false
+}
+
+// AbandonRoom can be called by any player of the room if host missed the phase deadline by the grace period.
+// Join and answer commissions are refunded to players from the room's pools and the room is finished.
+// Room can not be abandoned before the game starts, players leave it with LeaveRoom.
+func AbandonRoom(wallet interop.Hash160, roomId string) bool {
+	var ctx = storage.GetContext()
+	var room = getRoom(ctx, roomId)
+	authorize(wallet)
+
+	if _, found := getPlayer(ctx, roomId, wallet); !found || isRoomClosed(room) {
+		return false // Only player can abandon room, room must not be finished
+	}
+
+	if room.Status == StatusWaiting {
+		return false // Missed readiness deadline is caused by players, they can use LeaveRoom before the game starts
+	}
+
+	if ledger.CurrentIndex() <= room.PhaseDeadline+room.Timeouts.AbandonGrace {
+		return false // Grace period after the phase deadline has not passed yet
+	}
+
+	// Host abandoned the room, so create commission of the host goes to the platform
+	refundPlayers(ctx, room, rules.RemainderPlatform)
+	runtime.Notify("RoomAbandoned", room.Id, room.Status, room.PhaseDeadline)
+
+	setPhase(ctx, room, StatusFinished)
+	setRoom(ctx, room)
+	return true
+}
+
+// Function to return paid commissions to players from room's pools. If part of the pools was already paid out
+// as round rewards, the rest is shared in proportion to paid commissions. Remaining part of the pools is settled
+// as earnings of remainderTo, which is rules.RemainderHost or rules.RemainderPlatform
+func refundPlayers(ctx storage.Context, room *Room, remainderTo string) {
+	var available = room.GamePrizePool + room.RoundPrizePool
+	var players = getPlayers(ctx, room.Id)
+	var owed = 0
+	for _, player := range players {
+		owed += player.Paid
+	}
+
+	var refunded = 0
+	for _, player := range players {
+		var refund = rules.ProRataRefund(player.Paid, available, owed)
+
+		if refund == 0 {
+			continue
+		}
+
+		var result = contract.Call(getMoneyContractHash(ctx), "rewardPlayer", contract.All, room.Id, player.Wallet, refund).(bool)
+		if !result {
+			panic("Failed to refund commission")
+		}
+		runtime.Notify("RefundResult", room.Id, player.Wallet, refund, result)
+		refunded += refund
+	}
+
+	// Remaining part of the pools is create commission of the host and integer dust of pro rata refunds
+	if remainderTo == rules.RemainderHost {
+		settleEarnings(ctx, room, available-refunded, 0)
+	} else {
+		settleEarnings(ctx, room, 0, available-refunded)
+	}
+	room.GamePrizePool = 0
+	room.RoundPrizePool = 0
+}
+
+// READ-ONLY METHODS, marked as safe in room_contract.yml
+
+func playerToMap(player Player) map[string]any {
+	return map[string]any{
+		"wallet":          player.Wallet,
+		"paid":            player.Paid,
+		"roundsWon":       player.RoundsWon,
+		"isReady":         player.IsReady,
+		"isVotedToFinish": player.IsVotedToFinish,
+		"isActive":        player.isActive,
+	}
+}
+
+// Function to convert answer to map, content is empty until the answer is revealed
+func answerToMap(answer Answer) map[string]any {
+	return map[string]any{
+		"index":      answer.Index,
+		"wallet":     answer.Wallet,
+		"content":    answer.Content,
+		"isRevealed": answer.IsRevealed,
+		"votes":      answer.Votes,
+	}
+}
+
+func answersToList(ctx storage.Context, roomId string, roundIdx int, count int) []map[string]any {
+	var result = []map[string]any{}
+	for _, answer := range getAnswers(ctx, roomId, roundIdx, count) {
+		result = append(result, answerToMap(answer))
+	}
+
+	return result
+}
+
+func roundToMap(ctx storage.Context, roomId string, idx int) map[string]any {
+	var round = getRound(ctx, roomId, idx)
+	return map[string]any{
+		"index":    idx,
+		"tokenId":  round.TokenId,
+		"question": round.Question,
+		"answers":  answersToList(ctx, roomId, idx, round.AnswersCount),
+	}
+}
+
+// GetRoom returns room settings and state without players and rounds. Like every read-only method of the room,
+// it accepts both full and short room id
+func GetRoom(roomId string) map[string]any {
+	var ctx = storage.GetReadOnlyContext()
+	var room = getRoomByAnyId(ctx, roomId)
+
+	return map[string]any{
+		"id":                room.Id,
+		"shortId":           room.ShortId,
+		"host":              room.Host,
+		"status":            room.Status,
+		"token":             room.Token,
+		"commission":        room.Commission,
+		"gamePrizePool":     room.GamePrizePool,
+		"roundPrizePool":    room.RoundPrizePool,
+		"roundWinnersCount": room.RoundWinnersCount,
+		"gameWinnersCount":  room.GameWinnersCount,
+		"refundPolicy":      room.RefundPolicy,
+		"cancellationFee":   room.CancellationFee,
+		"tiePolicy":         room.TiePolicy,
+		"entryFee":          room.Config.EntryFee,
+		"answerFee":         room.Config.AnswerFee,
+		"hostShare":         room.Config.HostShare,
+		"gamePoolShare":     room.Config.GamePoolShare,
+		"remainderTo":       room.Config.RemainderTo,
+		"platformFee":       room.PlatformFee,
+		"minPlayers":        room.MinPlayers,
+		"maxPlayers":        room.MaxPlayers,
+		"isPrivate":         room.IsPrivate,
+		"phaseDeadline":     room.PhaseDeadline,
+		"abandonGrace":      room.Timeouts.AbandonGrace,
+		"players":           room.PlayersCount,
+		"rounds":            room.RoundsCount,
+	}
+}
+
+// ResolveRoom returns full room id by short id, empty string if room was not found
+func ResolveRoom(shortId string) string {
+	return resolveRoomId(storage.GetReadOnlyContext(), shortId)
+}
+
+func GetRoomStatus(roomId string) string {
+	var ctx = storage.GetReadOnlyContext()
+	return getRoomByAnyId(ctx, roomId).Status
+}
+
+func GetPlayers(roomId string) []map[string]any {
+	var ctx = storage.GetReadOnlyContext()
+	var room = getRoomByAnyId(ctx, roomId)
+
+	var result = []map[string]any{}
+	for _, player := range getPlayers(ctx, room.Id) {
+		result = append(result, playerToMap(player))
+	}
+
+	return result
+}
+
+// GetCurrentRound returns the last asked round, nil if no question was asked yet
+func GetCurrentRound(roomId string) map[string]any {
+	var ctx = storage.GetReadOnlyContext()
+	var room = getRoomByAnyId(ctx, roomId)
+	if room.RoundsCount == 0 {
+		return nil
+	}
+
+	return roundToMap(ctx, room.Id, room.RoundsCount-1)
+}
+
+func GetRound(roomId string, idx int) map[string]any {
+	var ctx = storage.GetReadOnlyContext()
+	var room = getRoomByAnyId(ctx, roomId)
+
+	return roundToMap(ctx, room.Id, idx)
+}
+
+// GetAnswers returns answers of the round, indexes of answers are the same as in VoteAnswer
+func GetAnswers(roomId string, roundIdx int) []map[string]any {
+	var ctx = storage.GetReadOnlyContext()
+	var room = getRoomByAnyId(ctx, roomId)
+
+	var round = getRound(ctx, room.Id, roundIdx)
+	return answersToList(ctx, room.Id, roundIdx, round.AnswersCount)
+}
+
+// GetStandings returns players ordered by count of won rounds, players with equal count are ordered by joining
+func GetStandings(roomId string) []map[string]any {
+	var ctx = storage.GetReadOnlyContext()
+	var room = getRoomByAnyId(ctx, roomId)
+
+	var players = getPlayers(ctx, room.Id)
+	var roundsWon = []int{}
+	var order = []int{}
+	for _, player := range players {
+		roundsWon = append(roundsWon, player.RoundsWon)
+		order = append(order, player.JoinedAt)
+	}
+
+	var result = []map[string]any{}
+	for i, idx := range rules.OrderByScore(roundsWon, order) {
+		var standing = playerToMap(players[idx])
+		standing["place"] = i
+		result = append(result, standing)
+	}
+
+	return result
+}
+
+// ListRooms returns page of room ids with the given status, limit must not be greater than maxListLimit.
+// Unlike RoomsOfHost and RoomsOfPlayer it returns an array instead of an iterator: iterators can not be skipped
+// on the contract side, so offset pagination is done here and every call walks offset+limit entries of the index
+func ListRooms(status string, offset int, limit int) []string {
+	if offset < 0 || limit <= 0 || limit > maxListLimit {
+		panic("Invalid pagination - offset must be >= 0 and limit must be in [1, " + std.Itoa10(maxListLimit) + "]")
+	}
+
+	var ctx = storage.GetReadOnlyContext()
+	var iter = storage.Find(ctx, []byte(statusIndexPrefix+status+":"), storage.ValuesOnly)
+
+	var result = []string{}
+	for i := 0; iterator.Next(iter) && len(result) < limit; i++ {
+		if i >= offset {
+			result = append(result, iterator.Value(iter).(string))
+		}
+	}
+
+	return result
+}
+
+// RoomsOfHost returns iterator over ids of rooms created by host
+func RoomsOfHost(wallet interop.Hash160) iterator.Iterator {
+	if len(wallet) != interop.Hash160Len {
+		panic("Host wallet:" + string(wallet) + " is not valid")
+	}
+	var ctx = storage.GetReadOnlyContext()
+
+	return storage.Find(ctx, append([]byte(hostIndexPrefix), wallet...), storage.ValuesOnly)
+}
+
+// RoomsOfPlayer returns iterator over ids of rooms joined by player
+func RoomsOfPlayer(wallet interop.Hash160) iterator.Iterator {
+	if len(wallet) != interop.Hash160Len {
+		panic("Player wallet:" + string(wallet) + " is not valid")
+	}
+	var ctx = storage.GetReadOnlyContext()
+
+	return storage.Find(ctx, append([]byte(playerIndexPrefix), wallet...), storage.ValuesOnly)
 }
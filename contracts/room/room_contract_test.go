@@ -0,0 +1,155 @@
+package room
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"strconv"
+	"testing"
+
+	"github.com/nspcc-dev/neo-go/pkg/core/native/nativenames"
+	"github.com/nspcc-dev/neo-go/pkg/neotest"
+	"github.com/nspcc-dev/neo-go/pkg/neotest/chain"
+	"github.com/nspcc-dev/neo-go/pkg/util"
+	"github.com/nspcc-dev/neo-go/pkg/vm/stackitem"
+)
+
+const (
+	testPlayers = 3
+	gasUnit     = 1_0000_0000
+)
+
+// Game deployed on a test chain: money, nft and room contracts, host and players of one room
+type testGame struct {
+	e       *neotest.Executor
+	gas     util.Uint160
+	nft     util.Uint160
+	room    util.Uint160
+	host    neotest.Signer
+	players []neotest.Signer
+	id      string
+}
+
+// VoteAnswer reads room header, round, answer and vote of the player by their keys, so GAS of the call must not grow
+// with played rounds. The only growth allowed is the new vote key, which has round index in decimal: every new digit
+// of the index is one more byte of storage
+func TestVoteAnswerGas(t *testing.T) {
+	var game = newTestGame(t)
+	var measured = map[int]int64{1: 0, 10: 0, 100: 0}
+	for round := 1; round <= 100; round++ {
+		var gas = game.playRound(t, round)
+		if _, exists := measured[round]; exists {
+			measured[round] = gas
+		}
+	}
+
+	t.Logf("VoteAnswer GAS after 1/10/100 rounds: %d/%d/%d", measured[1], measured[10], measured[100])
+	var storagePrice = int64(game.e.Chain.GetStoragePrice())
+	for _, rounds := range []int{10, 100} {
+		var keyGrowth = int64(len(strconv.Itoa(rounds-1)) - 1)
+		if want := measured[1] + keyGrowth*storagePrice; measured[rounds] != want {
+			t.Errorf("VoteAnswer after %d rounds consumed %d GAS, want %d", rounds, measured[rounds], want)
+		}
+	}
+}
+
+func newTestGame(t *testing.T) *testGame {
+	var bc, acc = chain.NewSingle(t)
+	var e = neotest.NewExecutor(t, bc, acc, acc)
+
+	var money = neotest.CompileFile(t, e.CommitteeHash, "../money", "../money/money_contract.yml")
+	e.DeployContract(t, money, nil)
+
+	var nft = neotest.CompileFile(t, e.CommitteeHash, "../nft", "../nft/nft_contract.yml")
+	e.DeployContract(t, nft, nil)
+
+	var room = neotest.CompileFile(t, e.CommitteeHash, ".", "room_contract.yml")
+	var data = `{"m":"` + base64.StdEncoding.EncodeToString(money.Hash.BytesBE()) +
+		`", "n":"` + base64.StdEncoding.EncodeToString(nft.Hash.BytesBE()) + `"}`
+	e.DeployContract(t, room, []byte(data))
+	e.CommitteeInvoker(money.Hash).Invoke(t, true, "authorizeGame", room.Hash)
+
+	var game = &testGame{
+		e:    e,
+		gas:  e.NativeHash(t, nativenames.Gas),
+		nft:  nft.Hash,
+		room: room.Hash,
+		host: e.NewAccount(t, 2000*gasUnit),
+	}
+
+	var created = game.transfer(t, game.host, game.room, 4*gasUnit, `{"action":"create", "roundWinners":1, "gameWinners":1}`)
+	game.id = game.eventArg(t, created, "RoomCreated")
+
+	for i := 0; i < testPlayers; i++ {
+		var player = e.NewAccount(t, 1000*gasUnit)
+		game.transfer(t, player, game.room, 2*gasUnit, `{"action":"join", "room":"`+game.id+`"}`)
+		game.invoke(t, player, "confirmReadiness", player.ScriptHash(), game.id)
+		game.players = append(game.players, player)
+	}
+	game.invoke(t, game.host, "startGame", game.host.ScriptHash(), game.id)
+
+	return game
+}
+
+// Function to play one round where every player answers and votes for the next player's answer,
+// returns GAS consumed by the vote of the first player
+func (g *testGame) playRound(t *testing.T, round int) int64 {
+	var question = "Question " + strconv.Itoa(round)
+	g.transfer(t, g.host, g.nft, 10*gasUnit, `{"question":"`+question+`"}`)
+	var tokenId = sha256.Sum256([]byte(question))
+	g.invoke(t, g.host, "askQuestion", g.host.ScriptHash(), g.id, tokenId[:])
+
+	var salt = make([]byte, saltLen)
+	for _, player := range g.players {
+		var commitment = answerCommitment(player.ScriptHash(), salt, "answer")
+		g.transfer(t, player, g.room, gasUnit,
+			`{"action":"answer", "room":"`+g.id+`", "commitment":"`+base64.StdEncoding.EncodeToString(commitment)+`"}`)
+	}
+	g.invoke(t, g.host, "endQuestion", g.host.ScriptHash(), g.id)
+
+	for _, player := range g.players {
+		g.invoke(t, player, "revealAnswer", player.ScriptHash(), g.id, "answer", salt)
+	}
+	g.invoke(t, g.host, "endReveal", g.host.ScriptHash(), g.id)
+
+	var gas int64
+	for i, player := range g.players {
+		var h = g.invoke(t, player, "voteAnswer", player.ScriptHash(), g.id, (i+1)%len(g.players))
+		if i == 0 {
+			gas = g.e.GetTxExecResult(t, h).GasConsumed
+		}
+	}
+	g.invoke(t, g.host, "getRoundWinner", g.host.ScriptHash(), g.id)
+
+	return gas
+}
+
+func (g *testGame) invoke(t *testing.T, signer neotest.Signer, method string, args ...any) util.Uint256 {
+	return g.e.NewInvoker(g.room, signer).Invoke(t, true, method, args...)
+}
+
+func (g *testGame) transfer(t *testing.T, from neotest.Signer, to util.Uint160, amount int64, data string) util.Uint256 {
+	return g.e.NewInvoker(g.gas, from).Invoke(t, true, "transfer", from.ScriptHash(), to, amount, []byte(data))
+}
+
+// Function to read the first argument of the room event emitted by the transaction
+func (g *testGame) eventArg(t *testing.T, h util.Uint256, name string) string {
+	for _, event := range g.e.GetTxExecResult(t, h).Events {
+		if event.Name == name && event.ScriptHash.Equals(g.room) {
+			var arg, err = event.Item.Value().([]stackitem.Item)[0].TryBytes()
+			if err != nil {
+				t.Fatal(err)
+			}
+			return string(arg)
+		}
+	}
+
+	t.Fatalf("event %s was not emitted", name)
+	return ""
+}
+
+// Function to build answer commitment off-chain the same way as makeCommitment
+func answerCommitment(wallet util.Uint160, salt []byte, content string) []byte {
+	var data = append(append(wallet.BytesBE(), salt...), content...)
+	var hash = sha256.Sum256(data)
+	return hash[:]
+}
@@ -0,0 +1,393 @@
+// Package rules contains game rules of the room contract: phase transitions, choosing winners,
+// payouts, refunds and deactivation of players. Functions here are pure and do not use interop,
+// so they can be run with go test and compiled by neo-go into the room contract.
+package rules
+
+// CONSTANTS
+
+const (
+	StatusWaiting   = "waiting"   // Waiting for the game to start, players are joining
+	StatusGaming    = "gaming"    // In this phase, players are ready, but the question hasn't been asked yet
+	StatusAnswering = "answering" // Phase when the round has started and the question has been asked, players can submit answer commitments
+	StatusRevealing = "revealing" // Phase when answering is closed and players reveal the answers behind their commitments
+	StatusVoting    = "voting"    // Voting phase, where players select the best answer from the options
+	StatusFinished  = "finished"  // Game is finished, and results have been determined
+	StatusCancelled = "cancelled" // Room was cancelled by host before the game started, commissions are refunded
+)
+
+const (
+	RefundFull     = "full" // Player gets back the whole join commission
+	RefundMinusFee = "fee"  // Player gets back join commission minus room's cancellation fee
+)
+
+// Tie policies decide who wins when entries share the last winning place
+const (
+	TieAll      = "all"      // All tied entries win the whole reward
+	TieSplit    = "split"    // All tied entries win, the reward of the places left is split between them
+	TieEarliest = "earliest" // Earliest entries win, e.g. the answer sent first
+	TieRandom   = "random"   // Winners are chosen randomly from tied entries
+)
+
+// Recipients of the payout remainder
+const (
+	RemainderHost     = "host"
+	RemainderPlatform = "platform"
+	RemainderWinner   = "winner" // Winner of the first place
+)
+
+const BpsDenominator = 10_000 // Shares are set in basis points
+
+// STRUCTS
+
+// Winner is a ranked entry, Index is the index of the entry in scores passed to Rank
+type Winner struct {
+	Index int
+	Place int // Count of entries with higher score, tied entries share the place
+	Score int
+	Tied  bool // Entry shares the place with other entries
+	Share int  // Part of the reward in basis points, less than BpsDenominator only with TieSplit
+}
+
+// Shares of the pool in basis points
+type Shares struct {
+	Platform int // Taken from the whole pool
+	GamePool int // Taken from the whole pool, moved from round pool to game pool
+	Host     int // Taken from the pool without platform and game pool shares
+}
+
+// Payout is every line of the pool distribution, sum of all lines is equal to Pool
+type Payout struct {
+	Pool        int
+	Rewards     []int // Rewards of winners, in the same order as winners
+	Host        int
+	GamePool    int
+	Platform    int
+	Remainder   int    // Part of the pool not assigned by shares and weights, already included into RemainderTo line
+	RemainderTo string // RemainderHost, RemainderPlatform or RemainderWinner
+}
+
+// STATE MACHINE
+
+// CanTransition reports whether the room can move from one status to another.
+// Empty status is the status of the room that is being created
+func CanTransition(from string, to string) bool {
+	switch from {
+	case "":
+		return to == StatusWaiting
+	case StatusWaiting:
+		return to == StatusGaming || to == StatusCancelled || to == StatusFinished
+	case StatusGaming:
+		return to == StatusAnswering || to == StatusFinished
+	case StatusAnswering:
+		return to == StatusRevealing || to == StatusFinished
+	case StatusRevealing:
+		return to == StatusVoting || to == StatusFinished
+	case StatusVoting:
+		return to == StatusGaming || to == StatusFinished
+	}
+
+	return false // Finished and cancelled rooms are closed
+}
+
+func IsClosed(status string) bool {
+	return status == StatusFinished || status == StatusCancelled
+}
+
+// CanStart reports whether the game can be started: every player is ready, there are at least minPlayers
+// players and more players than winners of the round
+func CanStart(playersCount int, readyCount int, minPlayers int, roundWinnersCount int) bool {
+	return playersCount > roundWinnersCount && playersCount >= minPlayers && readyCount == playersCount
+}
+
+// IsPlayerActive reports whether player can keep playing. Starting from the second round player must
+// reveal answer in the previous or in the current round
+func IsPlayerActive(roundsCount int, revealedPrevious bool, revealedCurrent bool) bool {
+	if roundsCount < 2 {
+		return true
+	}
+
+	return revealedPrevious || revealedCurrent
+}
+
+// SCORING
+
+func IsTiePolicy(policy string) bool {
+	return policy == TieAll || policy == TieSplit || policy == TieEarliest || policy == TieRandom
+}
+
+// OrderByScore returns indexes ordered by score from highest to lowest, equal scores are ordered by keys from lowest
+// to highest. Insertion sort is used, because sort package is not supported by NeoVM
+func OrderByScore(scores []int, keys []int) []int {
+	var order = []int{}
+	for i := range scores {
+		var pos = len(order)
+		for pos > 0 {
+			var prev = order[pos-1]
+			if scores[prev] > scores[i] || (scores[prev] == scores[i] && keys[prev] <= keys[i]) {
+				break
+			}
+			pos--
+		}
+		order = append(order, 0)
+		for j := len(order) - 1; j > pos; j-- {
+			order[j] = order[j-1]
+		}
+		order[pos] = i
+	}
+
+	return order
+}
+
+// Function to order entries which can win by score, entries with zero score never win
+func rankOrder(scores []int, keys []int) []int {
+	var order = []int{}
+	for _, idx := range OrderByScore(scores, keys) {
+		if scores[idx] > 0 {
+			order = append(order, idx)
+		}
+	}
+
+	return order
+}
+
+// Function to find score of the last winning place and entries with this score in the order of ranking,
+// count must be in [1, len(order)]
+func tieGroup(scores []int, order []int, count int) (int, []int) {
+	var boundary = scores[order[count-1]]
+	var group = []int{}
+	for _, idx := range order {
+		if scores[idx] == boundary {
+			group = append(group, idx)
+		}
+	}
+
+	return boundary, group
+}
+
+// TieGroup returns score of the last winning place and indexes of all entries with this score, ordered by keys.
+// These entries are the candidates whose places are decided by the tie policy in Rank. Group is empty when nobody can win
+func TieGroup(scores []int, keys []int, count int) (int, []int) {
+	var order = rankOrder(scores, keys)
+	if count <= 0 || len(order) == 0 {
+		return 0, []int{}
+	}
+
+	if count > len(order) {
+		count = len(order)
+	}
+	return tieGroup(scores, order, count)
+}
+
+// Rank chooses up to count winners by scores. Keys are used to order entries with equal scores, the lowest
+// key is the earliest entry. When entries share the last winning place, policy decides who of them wins,
+// seed is used only by TieRandom and must not be negative. Entries with zero score never win. Winners are ordered by place
+func Rank(scores []int, keys []int, count int, policy string, seed int) []Winner {
+	var order = rankOrder(scores, keys)
+	var winners = []Winner{}
+	if count <= 0 || len(order) == 0 {
+		return winners
+	}
+
+	if count > len(order) {
+		count = len(order)
+	}
+
+	// Entries above the last winning place win anyway, entries with the boundary score compete for slots left
+	var boundary, group = tieGroup(scores, order, count)
+	var above = 0
+	for above < len(order) && scores[order[above]] > boundary {
+		above++
+	}
+	var slots = count - above
+
+	var chosen = make([]bool, len(scores))
+	for i := 0; i < above; i++ {
+		chosen[order[i]] = true
+	}
+
+	var share = BpsDenominator
+	switch policy {
+	case TieAll, TieSplit:
+		for _, idx := range group {
+			chosen[idx] = true
+		}
+		if policy == TieSplit {
+			share = BpsDenominator * slots / len(group)
+		}
+	case TieEarliest:
+		for i := 0; i < slots; i++ {
+			chosen[group[i]] = true
+		}
+	case TieRandom:
+		var left = group
+		for i := 0; i < slots; i++ {
+			var pick = seed % len(left)
+			seed /= len(left)
+			chosen[left[pick]] = true
+
+			var rest = []int{}
+			for j, idx := range left {
+				if j != pick {
+					rest = append(rest, idx)
+				}
+			}
+			left = rest
+		}
+	default:
+		panic("Unknown tie policy")
+	}
+
+	for pos, idx := range order {
+		if !chosen[idx] {
+			continue
+		}
+
+		var place = 0
+		for place < pos && scores[order[place]] > scores[idx] {
+			place++
+		}
+		var tied = (pos > 0 && scores[order[pos-1]] == scores[idx]) ||
+			(pos+1 < len(order) && scores[order[pos+1]] == scores[idx])
+
+		var winnerShare = BpsDenominator
+		if scores[idx] == boundary {
+			winnerShare = share
+		}
+
+		winners = append(winners, Winner{Index: idx, Place: place, Score: scores[idx], Tied: tied, Share: winnerShare})
+	}
+
+	return winners
+}
+
+// Weight returns weight of winner for payouts, score multiplied by the share of the place in basis points.
+// Total weight of the pool must be multiplied by BpsDenominator too
+func Weight(winner Winner) int {
+	return winner.Score * winner.Share
+}
+
+// PAYOUTS
+
+// Share returns share of amount set in basis points
+func Share(amount int, shareBps int) int {
+	return amount * shareBps / BpsDenominator
+}
+
+// Distribute splits the pool between platform, game pool, host and winners. Platform and game pool shares
+// are taken from the whole pool, host share is taken from the rest, winners get the rest by their weights
+// of totalWeight. Everything that is not assigned - integer dust and weights missing to totalWeight - goes
+// to remainderTo, so the sum of the payout is always equal to the pool. Remainder of the winner goes to host
+// when there are no winners
+func Distribute(pool int, shares Shares, weights []int, totalWeight int, remainderTo string) Payout {
+	var payout = Payout{
+		Pool:        pool,
+		Rewards:     []int{},
+		Platform:    Share(pool, shares.Platform),
+		GamePool:    Share(pool, shares.GamePool),
+		RemainderTo: remainderTo,
+	}
+
+	var rest = pool - payout.Platform - payout.GamePool
+	payout.Host = Share(rest, shares.Host)
+
+	var winnersPool = rest - payout.Host
+	var assigned = payout.Platform + payout.GamePool + payout.Host
+	for _, weight := range weights {
+		var reward = 0
+		if totalWeight > 0 {
+			reward = winnersPool * weight / totalWeight
+		}
+		payout.Rewards = append(payout.Rewards, reward)
+		assigned += reward
+	}
+
+	payout.Remainder = pool - assigned
+	if payout.Remainder < 0 {
+		panic("Weights of winners must not be greater than total weight")
+	}
+	switch remainderTo {
+	case RemainderPlatform:
+		payout.Platform += payout.Remainder
+	case RemainderWinner:
+		if len(payout.Rewards) == 0 {
+			// No winner to get remainder, it goes to host, so the pool can always be distributed
+			payout.RemainderTo = RemainderHost
+			payout.Host += payout.Remainder
+			break
+		}
+		payout.Rewards[0] += payout.Remainder // Winner of the first place
+	case RemainderHost:
+		payout.Host += payout.Remainder
+	default:
+		panic("Unknown remainder recipient")
+	}
+
+	if PayoutTotal(payout) != pool {
+		panic("Sum of payouts must be equal to the pool")
+	}
+
+	return payout
+}
+
+func IsRemainderRecipient(recipient string) bool {
+	return recipient == RemainderHost || recipient == RemainderPlatform || recipient == RemainderWinner
+}
+
+// PayoutTotal returns sum of all lines of the payout
+func PayoutTotal(payout Payout) int {
+	var total = payout.Platform + payout.GamePool + payout.Host
+	for _, reward := range payout.Rewards {
+		total += reward
+	}
+
+	return total
+}
+
+// RoundPayout splits the round pool, winners get the rest by their votes
+func RoundPayout(pool int, shares Shares, winners []Winner, remainderTo string) Payout {
+	var weights = []int{}
+	var totalWeight = 0
+	for _, winner := range winners {
+		weights = append(weights, Weight(winner))
+		totalWeight += Weight(winner)
+	}
+
+	return Distribute(pool, shares, weights, totalWeight, remainderTo)
+}
+
+// GamePayout splits the game pool, winners get the rest by their won rounds of rounds won by all players,
+// round can have several winners. Part of players out of the winners goes to remainderTo.
+// Game pool share is not taken from the game pool
+func GamePayout(pool int, shares Shares, winners []Winner, totalWins int, remainderTo string) Payout {
+	var weights = []int{}
+	for _, winner := range winners {
+		weights = append(weights, Weight(winner))
+	}
+
+	shares.GamePool = 0
+	return Distribute(pool, shares, weights, totalWins*BpsDenominator, remainderTo)
+}
+
+// REFUNDS
+
+// RefundAmount returns refund of player who leaves the room or is removed on cancellation
+func RefundAmount(policy string, cancellationFee int, paid int) int {
+	if policy != RefundMinusFee {
+		return paid
+	}
+
+	if paid < cancellationFee {
+		return 0
+	}
+	return paid - cancellationFee
+}
+
+// ProRataRefund returns refund of abandoned room, if pools can't cover everything players paid,
+// every player gets back the same part of paid commissions
+func ProRataRefund(paid int, available int, owed int) int {
+	if owed <= available {
+		return paid
+	}
+
+	return paid * available / owed
+}
@@ -0,0 +1,440 @@
+package rules
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCanTransition(t *testing.T) {
+	var tests = []struct {
+		from string
+		to   string
+		want bool
+	}{
+		{"", StatusWaiting, true},
+		{"", StatusGaming, false},
+		{StatusWaiting, StatusGaming, true},
+		{StatusWaiting, StatusCancelled, true},
+		{StatusWaiting, StatusFinished, true},
+		{StatusWaiting, StatusAnswering, false},
+		{StatusGaming, StatusAnswering, true},
+		{StatusGaming, StatusFinished, true},
+		{StatusGaming, StatusCancelled, false},
+		{StatusAnswering, StatusRevealing, true},
+		{StatusAnswering, StatusVoting, false},
+		{StatusRevealing, StatusVoting, true},
+		{StatusRevealing, StatusGaming, false},
+		{StatusVoting, StatusGaming, true},
+		{StatusVoting, StatusFinished, true},
+		{StatusVoting, StatusAnswering, false},
+		{StatusFinished, StatusWaiting, false},
+		{StatusCancelled, StatusWaiting, false},
+	}
+
+	for _, test := range tests {
+		if got := CanTransition(test.from, test.to); got != test.want {
+			t.Errorf("CanTransition(%q, %q) = %v, want %v", test.from, test.to, got, test.want)
+		}
+	}
+}
+
+func TestCanStart(t *testing.T) {
+	var tests = []struct {
+		name         string
+		players      int
+		ready        int
+		minPlayers   int
+		roundWinners int
+		want         bool
+	}{
+		{name: "everyone is ready", players: 3, ready: 3, minPlayers: 2, roundWinners: 1, want: true},
+		{name: "not everyone is ready", players: 3, ready: 2, minPlayers: 2, roundWinners: 1, want: false},
+		{name: "less than min players", players: 2, ready: 2, minPlayers: 3, roundWinners: 1, want: false},
+		{name: "players equal to round winners", players: 2, ready: 2, minPlayers: 2, roundWinners: 2, want: false},
+		{name: "no players", players: 0, ready: 0, minPlayers: 0, roundWinners: 1, want: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := CanStart(test.players, test.ready, test.minPlayers, test.roundWinners); got != test.want {
+				t.Errorf("CanStart() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestIsPlayerActive(t *testing.T) {
+	var tests = []struct {
+		rounds           int
+		revealedPrevious bool
+		revealedCurrent  bool
+		want             bool
+	}{
+		{0, false, false, true},
+		{1, false, false, true},
+		{2, false, false, false},
+		{2, true, false, true},
+		{2, false, true, true},
+		{5, true, true, true},
+	}
+
+	for _, test := range tests {
+		if got := IsPlayerActive(test.rounds, test.revealedPrevious, test.revealedCurrent); got != test.want {
+			t.Errorf("IsPlayerActive(%d, %v, %v) = %v, want %v",
+				test.rounds, test.revealedPrevious, test.revealedCurrent, got, test.want)
+		}
+	}
+}
+
+func TestOrderByScore(t *testing.T) {
+	var got = OrderByScore([]int{1, 3, 0, 3}, []int{0, 5, 1, 2})
+	var want = []int{3, 1, 0, 2}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("OrderByScore() = %v, want %v", got, want)
+	}
+}
+
+func TestRank(t *testing.T) {
+	var scores = []int{5, 3, 3, 1, 0}
+	var keys = []int{0, 1, 2, 3, 4}
+
+	var tests = []struct {
+		name   string
+		scores []int
+		count  int
+		policy string
+		seed   int
+		want   []Winner
+	}{
+		{
+			name: "all tied entries win", scores: scores, count: 2, policy: TieAll,
+			want: []Winner{
+				{Index: 0, Place: 0, Score: 5, Share: BpsDenominator},
+				{Index: 1, Place: 1, Score: 3, Tied: true, Share: BpsDenominator},
+				{Index: 2, Place: 1, Score: 3, Tied: true, Share: BpsDenominator},
+			},
+		},
+		{
+			name: "tied entries split places left", scores: scores, count: 2, policy: TieSplit,
+			want: []Winner{
+				{Index: 0, Place: 0, Score: 5, Share: BpsDenominator},
+				{Index: 1, Place: 1, Score: 3, Tied: true, Share: BpsDenominator / 2},
+				{Index: 2, Place: 1, Score: 3, Tied: true, Share: BpsDenominator / 2},
+			},
+		},
+		{
+			name: "earliest tied entry wins", scores: scores, count: 2, policy: TieEarliest,
+			want: []Winner{
+				{Index: 0, Place: 0, Score: 5, Share: BpsDenominator},
+				{Index: 1, Place: 1, Score: 3, Tied: true, Share: BpsDenominator},
+			},
+		},
+		{
+			name: "random tied entry wins", scores: scores, count: 2, policy: TieRandom, seed: 1,
+			want: []Winner{
+				{Index: 0, Place: 0, Score: 5, Share: BpsDenominator},
+				{Index: 2, Place: 1, Score: 3, Tied: true, Share: BpsDenominator},
+			},
+		},
+		{
+			name: "count greater than entries", scores: []int{2, 1}, count: 5, policy: TieAll,
+			want: []Winner{
+				{Index: 0, Place: 0, Score: 2, Share: BpsDenominator},
+				{Index: 1, Place: 1, Score: 1, Share: BpsDenominator},
+			},
+		},
+		{
+			name: "zero scores never win", scores: []int{0, 0, 0}, count: 1, policy: TieAll,
+			want: []Winner{},
+		},
+		{
+			name: "zero count", scores: scores, count: 0, policy: TieAll,
+			want: []Winner{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got = Rank(test.scores, keys[:len(test.scores)], test.count, test.policy, test.seed)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Rank() = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestRankOrdersTiesByKeys(t *testing.T) {
+	var got = Rank([]int{4, 4, 4}, []int{7, 2, 5}, 1, TieEarliest, 0)
+	if len(got) != 1 || got[0].Index != 1 {
+		t.Errorf("Rank() = %+v, want entry with the lowest key", got)
+	}
+}
+
+func TestTieGroup(t *testing.T) {
+	var tests = []struct {
+		name      string
+		scores    []int
+		keys      []int
+		count     int
+		wantScore int
+		wantGroup []int
+	}{
+		{name: "tied last place", scores: []int{5, 3, 3, 1}, keys: []int{0, 1, 2, 3}, count: 2, wantScore: 3, wantGroup: []int{1, 2}},
+		{name: "ordered by keys", scores: []int{4, 4, 4}, keys: []int{7, 2, 5}, count: 1, wantScore: 4, wantGroup: []int{1, 2, 0}},
+		{name: "no tie", scores: []int{5, 3, 1}, keys: []int{0, 1, 2}, count: 2, wantScore: 3, wantGroup: []int{1}},
+		{name: "zero scores", scores: []int{0, 0}, keys: []int{0, 1}, count: 1, wantScore: 0, wantGroup: []int{}},
+		{name: "zero count", scores: []int{1}, keys: []int{0}, count: 0, wantScore: 0, wantGroup: []int{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var score, group = TieGroup(test.scores, test.keys, test.count)
+			if score != test.wantScore || !reflect.DeepEqual(group, test.wantGroup) {
+				t.Errorf("TieGroup() = %d, %v, want %d, %v", score, group, test.wantScore, test.wantGroup)
+			}
+		})
+	}
+}
+
+func TestDistribute(t *testing.T) {
+	var tests = []struct {
+		name        string
+		pool        int
+		shares      Shares
+		weights     []int
+		totalWeight int
+		remainderTo string
+		want        Payout
+	}{
+		{
+			name: "shares and weights", pool: 1000, shares: Shares{Platform: 500, GamePool: 2000, Host: 3000},
+			weights: []int{2, 1}, totalWeight: 3, remainderTo: RemainderHost,
+			want: Payout{Pool: 1000, Rewards: []int{350, 175}, Host: 225, GamePool: 200, Platform: 50, RemainderTo: RemainderHost},
+		},
+		{
+			name: "dust goes to winner", pool: 101, weights: []int{1, 1, 1}, totalWeight: 3, remainderTo: RemainderWinner,
+			want: Payout{Pool: 101, Rewards: []int{35, 33, 33}, Remainder: 2, RemainderTo: RemainderWinner},
+		},
+		{
+			name: "dust goes to platform", pool: 101, weights: []int{1, 1, 1}, totalWeight: 3, remainderTo: RemainderPlatform,
+			want: Payout{Pool: 101, Rewards: []int{33, 33, 33}, Platform: 2, Remainder: 2, RemainderTo: RemainderPlatform},
+		},
+		{
+			name: "missing weights go to host", pool: 100, weights: []int{1}, totalWeight: 4, remainderTo: RemainderHost,
+			want: Payout{Pool: 100, Rewards: []int{25}, Host: 75, Remainder: 75, RemainderTo: RemainderHost},
+		},
+		{
+			name: "winner remainder without winners goes to host", pool: 100, shares: Shares{Host: 3000},
+			weights: []int{}, totalWeight: 0, remainderTo: RemainderWinner,
+			want: Payout{Pool: 100, Rewards: []int{}, Host: 100, Remainder: 70, RemainderTo: RemainderHost},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got = Distribute(test.pool, test.shares, test.weights, test.totalWeight, test.remainderTo)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Distribute() = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestDistributePanics(t *testing.T) {
+	var tests = []struct {
+		name        string
+		weights     []int
+		totalWeight int
+		remainderTo string
+	}{
+		{name: "weights greater than total", weights: []int{5}, totalWeight: 1, remainderTo: RemainderHost},
+		{name: "unknown remainder recipient", weights: []int{1}, totalWeight: 1, remainderTo: "nobody"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("Distribute() did not panic")
+				}
+			}()
+			Distribute(100, Shares{}, test.weights, test.totalWeight, test.remainderTo)
+		})
+	}
+}
+
+func TestGamePayout(t *testing.T) {
+	var tests = []struct {
+		name      string
+		pool      int
+		shares    Shares
+		winners   []Winner
+		totalWins int
+		want      Payout
+	}{
+		{
+			name: "wins of other players go to remainder", pool: 1000, shares: Shares{Platform: 500, GamePool: 2000, Host: 3000},
+			winners: []Winner{{Score: 2, Share: BpsDenominator}}, totalWins: 4,
+			want: Payout{Pool: 1000, Rewards: []int{332}, Host: 618, Platform: 50, Remainder: 333, RemainderTo: RemainderHost},
+		},
+		{
+			name: "split winners", pool: 1000,
+			winners:   []Winner{{Score: 1, Tied: true, Share: BpsDenominator / 2}, {Index: 1, Score: 1, Tied: true, Share: BpsDenominator / 2}},
+			totalWins: 2,
+			want:      Payout{Pool: 1000, Rewards: []int{250, 250}, Host: 500, Remainder: 500, RemainderTo: RemainderHost},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got = GamePayout(test.pool, test.shares, test.winners, test.totalWins, RemainderHost)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("GamePayout() = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestRefundAmount(t *testing.T) {
+	var tests = []struct {
+		policy string
+		fee    int
+		paid   int
+		want   int
+	}{
+		{RefundFull, 10, 100, 100},
+		{RefundMinusFee, 10, 100, 90},
+		{RefundMinusFee, 200, 100, 0},
+		{RefundMinusFee, 0, 100, 100},
+	}
+
+	for _, test := range tests {
+		if got := RefundAmount(test.policy, test.fee, test.paid); got != test.want {
+			t.Errorf("RefundAmount(%q, %d, %d) = %d, want %d", test.policy, test.fee, test.paid, got, test.want)
+		}
+	}
+}
+
+func TestProRataRefund(t *testing.T) {
+	var tests = []struct {
+		paid      int
+		available int
+		owed      int
+		want      int
+	}{
+		{100, 500, 400, 100},
+		{100, 400, 400, 100},
+		{100, 300, 400, 75},
+		{100, 0, 400, 0},
+	}
+
+	for _, test := range tests {
+		if got := ProRataRefund(test.paid, test.available, test.owed); got != test.want {
+			t.Errorf("ProRataRefund(%d, %d, %d) = %d, want %d", test.paid, test.available, test.owed, got, test.want)
+		}
+	}
+}
+
+var tiePolicies = []string{TieAll, TieSplit, TieEarliest, TieRandom}
+var remainderRecipients = []string{RemainderHost, RemainderPlatform, RemainderWinner}
+
+func FuzzRank(f *testing.F) {
+	f.Add([]byte{5, 3, 3, 1, 0}, 2, uint8(0), 7)
+	f.Add([]byte{0, 0}, 1, uint8(2), 0)
+	f.Add([]byte{1, 1, 1, 1}, 3, uint8(3), 12345)
+
+	f.Fuzz(func(t *testing.T, data []byte, count int, policyIdx uint8, seed int) {
+		var scores = []int{}
+		var keys = []int{}
+		var positive = 0
+		for i, b := range data {
+			scores = append(scores, int(b))
+			keys = append(keys, len(data)-i) // Later entries are earlier to check ordering by keys
+			if b > 0 {
+				positive++
+			}
+		}
+		if seed < 0 {
+			seed = -(seed + 1)
+		}
+		var policy = tiePolicies[int(policyIdx)%len(tiePolicies)]
+
+		var winners = Rank(scores, keys, count, policy, seed)
+
+		var expected = min(count, positive)
+		if count <= 0 {
+			expected = 0
+		}
+		if (policy == TieEarliest || policy == TieRandom) && len(winners) != expected {
+			t.Fatalf("%s: got %d winners, want %d", policy, len(winners), expected)
+		}
+		if len(winners) < expected {
+			t.Fatalf("%s: got %d winners, want at least %d", policy, len(winners), expected)
+		}
+
+		var seen = map[int]bool{}
+		var totalShare = 0
+		for i, winner := range winners {
+			if winner.Score <= 0 || seen[winner.Index] || winner.Share <= 0 || winner.Share > BpsDenominator {
+				t.Fatalf("%s: invalid winner %+v", policy, winner)
+			}
+			if i > 0 && winner.Place < winners[i-1].Place {
+				t.Fatalf("%s: winners are not ordered by place: %+v", policy, winners)
+			}
+			seen[winner.Index] = true
+			totalShare += winner.Share
+		}
+		if policy == TieSplit && totalShare > expected*BpsDenominator {
+			t.Fatalf("split: shares %d are greater than %d places", totalShare, expected)
+		}
+
+		var payout = RoundPayout(1_000_003, Shares{Platform: 500, GamePool: 2000, Host: 3000}, winners, RemainderWinner)
+		if PayoutTotal(payout) != payout.Pool {
+			t.Fatalf("%s: payout total %d is not equal to the pool %d", policy, PayoutTotal(payout), payout.Pool)
+		}
+	})
+}
+
+func FuzzDistribute(f *testing.F) {
+	f.Add(1000, uint16(500), uint16(2000), uint16(3000), []byte{2, 1}, uint8(0), uint8(0))
+	f.Add(101, uint16(0), uint16(0), uint16(0), []byte{1, 1, 1}, uint8(2), uint8(0))
+	f.Add(100, uint16(0), uint16(0), uint16(3000), []byte{}, uint8(2), uint8(9))
+
+	f.Fuzz(func(t *testing.T, pool int, platform uint16, gamePool uint16, host uint16, data []byte,
+		remainderIdx uint8, missing uint8) {
+		if pool < 0 {
+			pool = -(pool + 1)
+		}
+		pool %= 1_000_000_000_000 // Keeps pool * weight within int
+		var shares = Shares{
+			Platform: int(platform) % 5001,
+			GamePool: int(gamePool) % 5001,
+			Host:     int(host) % 5001,
+		}
+
+		var weights = []int{}
+		var totalWeight = int(missing) // Part of total weight not assigned to winners
+		for _, b := range data {
+			weights = append(weights, int(b))
+			totalWeight += int(b)
+		}
+		var remainderTo = remainderRecipients[int(remainderIdx)%len(remainderRecipients)]
+
+		var payout = Distribute(pool, shares, weights, totalWeight, remainderTo)
+
+		if PayoutTotal(payout) != pool {
+			t.Fatalf("payout total %d is not equal to the pool %d", PayoutTotal(payout), pool)
+		}
+		if payout.Host < 0 || payout.GamePool < 0 || payout.Platform < 0 || payout.Remainder < 0 {
+			t.Fatalf("negative line in payout %+v", payout)
+		}
+		if len(payout.Rewards) != len(weights) {
+			t.Fatalf("got %d rewards, want %d", len(payout.Rewards), len(weights))
+		}
+		for _, reward := range payout.Rewards {
+			if reward < 0 {
+				t.Fatalf("negative reward in payout %+v", payout)
+			}
+		}
+	})
+}